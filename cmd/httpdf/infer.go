@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sehrgutesoftware/httpdf/internal/subdirfs"
+	"github.com/sehrgutesoftware/httpdf/internal/template"
+)
+
+// inferCommand compares the fields used by the templates with their schemas,
+// or proposes a draft schema
+func inferCommand(args []string) error {
+	fs := flag.NewFlagSet("infer", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: httpdf infer [flags] [template...]")
+		fmt.Fprintln(fs.Output(), "\nLists the fields the templates (default: all) use, but their schemas don't declare.")
+		fs.PrintDefaults()
+	}
+	templates := fs.String("templates", "templates", "directory of the templates")
+	draft := fs.Bool("draft", false, "print a draft schema inferred from the fields used by a single template instead")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	loader := template.NewFSLoader(subdirfs.New(*templates))
+	names := fs.Args()
+	if *draft {
+		if len(names) != 1 {
+			return errors.New("-draft requires exactly one template")
+		}
+		return printDraft(loader, names[0])
+	}
+	if len(names) == 0 {
+		var err error
+		if names, err = loader.List(); err != nil {
+			return err
+		}
+	}
+
+	failed := 0
+	for _, name := range names {
+		t, err := loader.Load(name)
+		if err != nil {
+			return fmt.Errorf("load template %s: %w", name, err)
+		}
+		fields, err := t.UndeclaredFields()
+		if err != nil {
+			return fmt.Errorf("infer fields of %s: %w", name, err)
+		}
+		if len(fields) == 0 {
+			fmt.Printf("ok %s\n", name)
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL %s\n", name)
+		for _, field := range fields {
+			fmt.Printf("    %s is used, but not declared\n", field)
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d template(s) use undeclared fields\n", failed)
+		return errFailed
+	}
+	return nil
+}
+
+// printDraft prints the schema inferred from the template
+func printDraft(loader template.Loader, name string) error {
+	t, err := loader.Load(name)
+	if err != nil {
+		return fmt.Errorf("load template %s: %w", name, err)
+	}
+	s, err := t.InferSchema()
+	if err != nil {
+		return fmt.Errorf("infer schema of %s: %w", name, err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(s)
+}
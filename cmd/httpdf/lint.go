@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sehrgutesoftware/httpdf/internal/subdirfs"
+	"github.com/sehrgutesoftware/httpdf/internal/template"
+)
+
+// lintCommand checks the templates for errors that would otherwise only
+// surface when rendering them
+func lintCommand(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: httpdf lint [flags] [template...]")
+		fmt.Fprintln(fs.Output(), "\nChecks the templates (default: all) for errors.")
+		fs.PrintDefaults()
+	}
+	templates := fs.String("templates", "templates", "directory of the templates")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	loader := template.NewFSLoader(subdirfs.New(*templates))
+	names := fs.Args()
+	if len(names) == 0 {
+		var err error
+		if names, err = loader.List(); err != nil {
+			return err
+		}
+	}
+
+	failed := 0
+	for _, name := range names {
+		issues := loader.Lint(name)
+		if len(issues) == 0 {
+			fmt.Printf("ok %s\n", name)
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL %s\n", name)
+		for _, issue := range issues {
+			fmt.Printf("    %s\n", issue)
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d template(s) have issues\n", failed)
+		return errFailed
+	}
+	return nil
+}
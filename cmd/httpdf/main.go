@@ -0,0 +1,112 @@
+// Command httpdf works with templates without running a server.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/sehrgutesoftware/httpdf"
+	"github.com/sehrgutesoftware/httpdf/internal/pdf"
+)
+
+// errFailed signals a failure that has already been reported
+var errFailed = errors.New("failed")
+
+const usage = `Usage: httpdf <command> [flags]
+
+Commands:
+  infer     list fields used by templates, but not declared in their schemas
+  lint      check templates for errors
+  new       create a new template from a starter kit
+  render    render a template to PDF
+  snapshot  compare renderings of the template examples against golden images
+  types     generate Go types from the schemas of templates
+
+Run "httpdf <command> -h" for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "infer":
+		err = inferCommand(args)
+	case "lint":
+		err = lintCommand(args)
+	case "new":
+		err = newCommand(args)
+	case "render":
+		err = renderCommand(args)
+	case "snapshot":
+		err = snapshotCommand(args)
+	case "types":
+		err = typesCommand(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "httpdf: unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if errors.Is(err, errFailed) {
+		os.Exit(1)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "httpdf: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// rendererFlags selects the renderer, like the flags of the server
+type rendererFlags struct {
+	browser        *string
+	chromium       *string
+	firefox        *string
+	remoteChromium *string
+	verbose        *bool
+}
+
+func addRendererFlags(fs *flag.FlagSet) *rendererFlags {
+	return &rendererFlags{
+		browser:        fs.String("browser", "chromium", "default renderer: chromium, firefox or native; templates can select another one"),
+		chromium:       fs.String("chromium", "/usr/bin/chromium", "path of the Chromium binary"),
+		firefox:        fs.String("firefox", "/usr/bin/firefox", "path of the Firefox binary"),
+		remoteChromium: fs.String("remote-chromium", "", "DevTools endpoint of a running Chromium to connect to instead of launching one"),
+		verbose:        fs.Bool("v", false, "log the rendering details, e.g. page diagnostics"),
+	}
+}
+
+// app creates the httpdf service with the selected renderers
+func (f *rendererFlags) app() (httpdf.HTTPDF, error) {
+	if !*f.verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	renderers := map[string]pdf.Renderer{
+		"chromium": pdf.NewRodRenderer(*f.chromium),
+		"firefox":  pdf.NewFirefoxRenderer(*f.firefox),
+		"native":   pdf.NewNativeRenderer(),
+	}
+	if *f.remoteChromium != "" {
+		renderers["chromium"] = pdf.NewRemoteRenderer(*f.remoteChromium)
+	}
+	pdfRenderer, ok := renderers[*f.browser]
+	if !ok {
+		return nil, fmt.Errorf("unknown browser %q", *f.browser)
+	}
+	var opts []httpdf.Option
+	for name, r := range renderers {
+		opts = append(opts, httpdf.WithRenderer(name, r))
+	}
+	return httpdf.New(pdfRenderer, opts...), nil
+}
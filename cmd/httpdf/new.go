@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// kits contains the starter kits for new templates. Their files are
+// templates themselves, using [[ ]] as delimiters, so that the template
+// actions are copied as they are.
+//
+//go:embed kits
+var kits embed.FS
+
+// kitPapers is the default paper size of each kit
+var kitPapers = map[string]string{
+	"blank":   "a4",
+	"letter":  "a4",
+	"invoice": "a4",
+	"label":   "100x150",
+}
+
+// papers are the named paper sizes in mm, in portrait orientation
+var papers = map[string][2]float64{
+	"a3":     {297, 420},
+	"a4":     {210, 297},
+	"a5":     {148, 210},
+	"a6":     {105, 148},
+	"letter": {215.9, 279.4},
+	"legal":  {215.9, 355.6},
+}
+
+var configTemplate = template.Must(template.New("config").Parse(`page:
+  width: {{ .Width }}
+  height: {{ .Height }}
+
+locale:
+  locales:
+{{- range .Locales }}
+    - {{ . }}
+{{- end }}
+  default: {{ index .Locales 0 }}
+`))
+
+// scaffold are the values available in the files of the starter kits
+type scaffold struct {
+	Name    string
+	Width   float64
+	Height  float64
+	Locales []string
+}
+
+// newCommand creates a new template from a starter kit
+func newCommand(args []string) error {
+	flags := flag.NewFlagSet("new", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: httpdf new [flags] <name>")
+		fmt.Fprintln(flags.Output(), "\nCreates a new template from a starter kit.")
+		flags.PrintDefaults()
+	}
+	templates := flags.String("templates", "templates", "directory of the templates")
+	kit := flags.String("kit", "blank", "starter kit: "+strings.Join(slices.Sorted(maps.Keys(kitPapers)), ", "))
+	paper := flags.String("paper", "", "paper size: "+strings.Join(slices.Sorted(maps.Keys(papers)), ", ")+" or {width}x{height} in mm (default: depends on the kit)")
+	landscape := flags.Bool("landscape", false, "use the paper in landscape orientation")
+	lang := flags.String("lang", "en", "comma-separated locales, the first one is the default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected exactly one template name")
+	}
+
+	name := flags.Arg(0)
+	if !validName(name) {
+		return fmt.Errorf("invalid template name %q", name)
+	}
+	if _, ok := kitPapers[*kit]; !ok {
+		return fmt.Errorf("unknown kit %q", *kit)
+	}
+	if *paper == "" {
+		*paper = kitPapers[*kit]
+	}
+	width, height, err := parsePaper(*paper)
+	if err != nil {
+		return err
+	}
+	if *landscape {
+		width, height = max(width, height), min(width, height)
+	}
+	var locales []string
+	for l := range strings.SplitSeq(*lang, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			locales = append(locales, l)
+		}
+	}
+	if len(locales) == 0 {
+		return errors.New("at least one locale is required")
+	}
+
+	dir := filepath.Join(*templates, name)
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("%s already exists", dir)
+	}
+	values := scaffold{Name: name, Width: width, Height: height, Locales: locales}
+	if err := writeScaffold(dir, *kit, values); err != nil {
+		_ = os.RemoveAll(dir)
+		return err
+	}
+
+	fmt.Printf("created %s from the %s kit\n", dir, *kit)
+	return nil
+}
+
+// writeScaffold writes the files of the kit and the config to dir
+func writeScaffold(dir, kit string, values scaffold) error {
+	root := path.Join("kits", kit)
+	err := fs.WalkDir(kits, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		// The locale files are written for the chosen locales below
+		rel := strings.TrimPrefix(p, root+"/")
+		if path.Dir(rel) == "locales" {
+			return nil
+		}
+		return writeKitFile(filepath.Join(dir, filepath.FromSlash(rel)), p, values)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Locales not provided by the kit start out as copies of the English
+	// translations
+	for _, locale := range values.Locales {
+		src := path.Join(root, "locales", locale+".yaml")
+		if _, err := fs.Stat(kits, src); err != nil {
+			src = path.Join(root, "locales", "en.yaml")
+		}
+		if err := writeKitFile(filepath.Join(dir, "locales", locale+".yaml"), src, values); err != nil {
+			return err
+		}
+	}
+
+	var config bytes.Buffer
+	if err := configTemplate.Execute(&config, values); err != nil {
+		return fmt.Errorf("render config: %w", err)
+	}
+	return writeFile(filepath.Join(dir, "config.yaml"), config.Bytes())
+}
+
+// writeKitFile renders the file of the kit to dst
+func writeKitFile(dst, src string, values scaffold) error {
+	content, err := fs.ReadFile(kits, src)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", src, err)
+	}
+	t, err := template.New(path.Base(src)).Delims("[[", "]]").Parse(string(content))
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", src, err)
+	}
+	var rendered bytes.Buffer
+	if err := t.Execute(&rendered, values); err != nil {
+		return fmt.Errorf("render %s: %w", src, err)
+	}
+	return writeFile(dst, rendered.Bytes())
+}
+
+// writeFile writes the file, creating its directory if necessary
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
+
+// parsePaper returns the size of a named paper or of {width}x{height} in mm
+func parsePaper(paper string) (float64, float64, error) {
+	if size, ok := papers[strings.ToLower(paper)]; ok {
+		return size[0], size[1], nil
+	}
+	w, h, ok := strings.Cut(strings.ToLower(paper), "x")
+	width, werr := strconv.ParseFloat(w, 64)
+	height, herr := strconv.ParseFloat(h, 64)
+	if !ok || werr != nil || herr != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid paper size %q", paper)
+	}
+	return width, height, nil
+}
+
+// validName reports whether name can be used as directory of a template.
+// Directories starting with _ are reserved for shared files, e.g. _partials.
+func validName(name string) bool {
+	return name != "" && fs.ValidPath(name) && !strings.Contains(name, "/") && !strings.HasPrefix(name, ".") && !strings.HasPrefix(name, "_")
+}
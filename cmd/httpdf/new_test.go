@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sehrgutesoftware/httpdf/internal/subdirfs"
+	"github.com/sehrgutesoftware/httpdf/internal/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCommand(t *testing.T) {
+	for kit := range kitPapers {
+		t.Run("it_creates_a_valid_template_from_the_"+kit+"_kit", func(t *testing.T) {
+			dir := t.TempDir()
+
+			err := newCommand([]string{"-templates", dir, "-kit", kit, "-lang", "de,fr", "test"})
+
+			require.NoError(t, err)
+			assert.Empty(t, template.NewFSLoader(subdirfs.New(dir)).Lint("test"))
+		})
+	}
+
+	t.Run("it_writes_the_paper_size_and_locales_to_the_config", func(t *testing.T) {
+		dir := t.TempDir()
+
+		err := newCommand([]string{"-templates", dir, "-paper", "a5", "-landscape", "-lang", "en,de", "test"})
+
+		require.NoError(t, err)
+		tmpl, err := template.NewFSLoader(subdirfs.New(dir)).Load("test")
+		require.NoError(t, err)
+		assert.Equal(t, 210.0, tmpl.Config.Page.Width)
+		assert.Equal(t, 148.0, tmpl.Config.Page.Height)
+		assert.Equal(t, []string{"en", "de"}, tmpl.Config.Locale.Locales)
+		assert.Equal(t, "en", tmpl.Config.Locale.Default)
+		assert.DirExists(t, filepath.Join(dir, "test", "assets"))
+	})
+
+	t.Run("it_refuses_to_overwrite_an_existing_template", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "test"), 0o755))
+
+		err := newCommand([]string{"-templates", dir, "test"})
+
+		assert.ErrorContains(t, err, "already exists")
+	})
+
+	t.Run("it_rejects_invalid_paper_sizes", func(t *testing.T) {
+		err := newCommand([]string{"-templates", t.TempDir(), "-paper", "huge", "test"})
+
+		assert.ErrorContains(t, err, "invalid paper size")
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sehrgutesoftware/httpdf/internal/subdirfs"
+	"github.com/sehrgutesoftware/httpdf/internal/template"
+)
+
+// renderCommand renders a template to PDF
+func renderCommand(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: httpdf render -template <name> [flags]")
+		fmt.Fprintln(fs.Output(), "\nRenders the template with the JSON values to PDF.")
+		fs.PrintDefaults()
+	}
+	templates := fs.String("templates", "templates", "directory of the templates")
+	name := fs.String("template", "", "name of the template to render")
+	valuesPath := fs.String("values", "-", "JSON file with the values, - for stdin")
+	lang := fs.String("lang", "", "locale to render the template in")
+	out := fs.String("o", "-", "output file, - for stdout")
+	renderer := addRendererFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		fs.Usage()
+		return errors.New("missing -template")
+	}
+
+	values, err := readValues(*valuesPath)
+	if err != nil {
+		return err
+	}
+	t, err := template.NewFSLoader(subdirfs.New(*templates)).Load(*name)
+	if err != nil {
+		return fmt.Errorf("load template: %w", err)
+	}
+	app, err := renderer.app()
+	if err != nil {
+		return err
+	}
+
+	// The PDF is buffered, so that no partial file is left behind on failure
+	var document bytes.Buffer
+	if err := app.Generate(context.Background(), t, *lang, values, &document); err != nil {
+		return err
+	}
+	if *out == "-" {
+		_, err = os.Stdout.Write(document.Bytes())
+	} else {
+		err = os.WriteFile(*out, document.Bytes(), 0o644)
+	}
+	if err != nil {
+		return fmt.Errorf("write PDF: %w", err)
+	}
+	return nil
+}
+
+// readValues decodes the JSON values from the file at path, or from stdin
+// for -
+func readValues(path string) (map[string]any, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("read values: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var values map[string]any
+	if err := json.NewDecoder(r).Decode(&values); err != nil {
+		return nil, fmt.Errorf("decode values: %w", err)
+	}
+	return values, nil
+}
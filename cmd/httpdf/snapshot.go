@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sehrgutesoftware/httpdf/internal/subdirfs"
+	"github.com/sehrgutesoftware/httpdf/snapshot"
+)
+
+// snapshotCommand renders the examples of the templates to PNG and compares
+// them against the golden images
+func snapshotCommand(args []string) error {
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: httpdf snapshot [flags] [template...]")
+		fmt.Fprintln(fs.Output(), "\nCompares the examples of the templates (default: all) against the golden images.")
+		fs.PrintDefaults()
+	}
+	templates := fs.String("templates", "templates", "directory of the templates")
+	golden := fs.String("golden", "snapshots", "directory of the golden images")
+	diffs := fs.String("diffs", "", "directory for the diff images of failed comparisons (default: the golden directory)")
+	threshold := fs.Float64("threshold", 0, "fraction of pixels that may differ, e.g. 0.001")
+	tolerance := fs.Uint("tolerance", 0, "difference per color channel (0-255) up to which pixels are considered equal")
+	update := fs.Bool("update", false, "write the current renderings as golden images")
+	lang := fs.String("lang", "", "locale to render the templates in")
+	renderer := addRendererFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *tolerance > 255 {
+		return fmt.Errorf("tolerance must be between 0 and 255")
+	}
+
+	app, err := renderer.app()
+	if err != nil {
+		return err
+	}
+
+	runner := snapshot.New(app, subdirfs.New(*templates), snapshot.Options{
+		Golden:    *golden,
+		Diffs:     *diffs,
+		Threshold: *threshold,
+		Tolerance: uint8(*tolerance),
+		Update:    *update,
+		Locale:    *lang,
+	})
+	failed := 0
+	for _, result := range runner.Run(context.Background(), fs.Args()...) {
+		fmt.Println(result)
+		if result.Failed() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d snapshot(s) failed\n", failed)
+		return errFailed
+	}
+	return nil
+}
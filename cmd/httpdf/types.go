@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sehrgutesoftware/httpdf/internal/subdirfs"
+	"github.com/sehrgutesoftware/httpdf/internal/template"
+	"github.com/sehrgutesoftware/httpdf/schema"
+)
+
+// typesCommand generates Go types from the schemas of the templates
+func typesCommand(args []string) error {
+	fs := flag.NewFlagSet("types", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: httpdf types [flags] [template...]")
+		fmt.Fprintln(fs.Output(), "\nGenerates Go types from the schemas of the templates (default: all).")
+		fs.PrintDefaults()
+	}
+	templates := fs.String("templates", "templates", "directory of the templates")
+	pkg := fs.String("package", "templates", "package name of the generated file")
+	out := fs.String("o", "-", "output file, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	loader := template.NewFSLoader(subdirfs.New(*templates))
+	names := fs.Args()
+	if len(names) == 0 {
+		var err error
+		if names, err = loader.List(); err != nil {
+			return err
+		}
+	}
+
+	schemas := make(map[string][]byte, len(names))
+	for _, name := range names {
+		t, err := loader.Load(name)
+		if err != nil {
+			return fmt.Errorf("load template %s: %w", name, err)
+		}
+		if schemas[name], err = json.Marshal(t.Schema); err != nil {
+			return fmt.Errorf("encode schema of %s: %w", name, err)
+		}
+	}
+	src, err := schema.GenerateGo(*pkg, schemas)
+	if err != nil {
+		return err
+	}
+
+	if *out == "-" {
+		_, err = os.Stdout.Write(src)
+	} else {
+		err = os.WriteFile(*out, src, 0o644)
+	}
+	if err != nil {
+		return fmt.Errorf("write types: %w", err)
+	}
+	return nil
+}
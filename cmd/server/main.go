@@ -1,8 +1,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"log"
 	"net/http"
+	"strings"
 
 	"github.com/sehrgutesoftware/httpdf"
 	"github.com/sehrgutesoftware/httpdf/internal/pdf"
@@ -11,13 +14,56 @@ import (
 )
 
 func main() {
+	browser := flag.String("browser", "chromium", "default renderer: chromium, firefox or native; templates can select another one")
+	chromium := flag.String("chromium", "/usr/bin/chromium", "path of the Chromium binary to launch for each render")
+	firefox := flag.String("firefox", "/usr/bin/firefox", "path of the Firefox binary to launch for each render")
+	remoteChromium := flag.String("remote-chromium", "", "DevTools endpoint of a running Chromium to connect to instead of launching one, e.g. http://chromium:9222")
+	advertiseHost := flag.String("advertise-host", "", "host name under which a remote Chromium reaches this server")
+	diagnosticsHeader := flag.Bool("diagnostics-header", false, "expose page diagnostics in the X-PDF-Diagnostics response header")
+	adHocTemplates := flag.Bool("adhoc-templates", false, "enable POST /render for templates uploaded in the request; don't use in production")
+	urlAllowlist := flag.String("url-allowlist", "", "comma-separated hosts or URL patterns POST /url may render, e.g. intranet.example.com,*.internal")
+	devMode := flag.Bool("dev", false, "reload previews automatically when templates change, and show template errors in an overlay")
+	flag.Parse()
+
 	listenOn := ":8080"
 	fmt.Printf("Starting httpdf server on http://localhost%s\n", listenOn)
 
-	loader := template.NewFSLoader(subdirfs.New("templates"))
-	pdfRenderer := pdf.NewRodRenderer("/usr/bin/chromium")
-	app := httpdf.New(pdfRenderer)
-	server := httpdf.NewServer(app, loader)
+	templates := subdirfs.New("templates")
+	loader := template.NewFSLoader(templates)
+	renderers := map[string]pdf.Renderer{
+		"chromium": pdf.NewRodRenderer(*chromium),
+		"firefox":  pdf.NewFirefoxRenderer(*firefox),
+		"native":   pdf.NewNativeRenderer(),
+	}
+	if *remoteChromium != "" {
+		renderers["chromium"] = pdf.NewRemoteRenderer(*remoteChromium)
+	}
+	pdfRenderer, ok := renderers[*browser]
+	if !ok {
+		log.Fatalf("unknown browser %q", *browser)
+	}
+	var opts []httpdf.Option
+	for name, r := range renderers {
+		opts = append(opts, httpdf.WithRenderer(name, r))
+	}
+	if *advertiseHost != "" {
+		opts = append(opts, httpdf.WithAdvertisedHost(*advertiseHost))
+	}
+	app := httpdf.New(pdfRenderer, opts...)
+	var serverOpts []httpdf.ServerOption
+	if *diagnosticsHeader {
+		serverOpts = append(serverOpts, httpdf.WithDiagnosticsHeader())
+	}
+	if *urlAllowlist != "" {
+		serverOpts = append(serverOpts, httpdf.WithURLAllowlist(strings.Split(*urlAllowlist, ",")...))
+	}
+	if *devMode {
+		serverOpts = append(serverOpts, httpdf.WithDevMode(templates))
+	}
+	if *adHocTemplates {
+		serverOpts = append(serverOpts, httpdf.WithAdHocTemplates())
+	}
+	server := httpdf.NewServer(app, loader, serverOpts...)
 
 	err := http.ListenAndServe(listenOn, server)
 	if err != nil {
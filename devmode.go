@@ -0,0 +1,148 @@
+package httpdf
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	htmltemplate "html/template"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/sehrgutesoftware/httpdf/internal/template"
+)
+
+// devPollInterval is the interval in which the template files are checked
+// for changes in dev mode
+const devPollInterval = 500 * time.Millisecond
+
+// WithDevMode enables live reloading of the preview. The preview page listens
+// on GET /templates/{template}/events and reloads as soon as any file of the
+// template, of the templates it extends or of the shared layouts and partials
+// in templates changes; errors are shown in an overlay instead of a plain
+// error response. templates must be the filesystem the loader reads from.
+func WithDevMode(templates fs.FS) ServerOption {
+	return func(s *server) {
+		s.devTemplates = templates
+	}
+}
+
+// events notifies the preview page about changes to the template files using
+// Server-Sent Events
+func (s *server) events(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("template")
+	if !fs.ValidPath(name) {
+		http.Error(w, "template not found", http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	// A comment opens the stream, so the page knows it's connected
+	fmt.Fprint(w, ": watching\n\n")
+	flusher.Flush()
+
+	dirs := s.watchedDirs(name)
+	last := fingerprint(s.devTemplates, dirs...)
+	ticker := time.NewTicker(devPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			current := fingerprint(s.devTemplates, dirs...)
+			if current == last {
+				continue
+			}
+			// The change may have added or removed a base template
+			dirs = s.watchedDirs(name)
+			last = fingerprint(s.devTemplates, dirs...)
+			if _, err := fmt.Fprint(w, "event: reload\ndata: {}\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// watchedDirs returns the directories of the files the template is made of:
+// its own, those of the templates it extends, and the shared ones
+func (s *server) watchedDirs(name string) []string {
+	dirs := []string{name}
+	if t, err := s.loader.Load(name); err == nil {
+		dirs = append(dirs, t.Bases...)
+	}
+	return append(dirs, template.SharedDirs()...)
+}
+
+// fingerprint hashes the paths, sizes and modification times of all files in
+// the directories, so that any change to them changes the fingerprint
+func fingerprint(fsys fs.FS, dirs ...string) uint64 {
+	h := fnv.New64a()
+	for _, dir := range dirs {
+		_ = fs.WalkDir(fsys, dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				fmt.Fprintf(h, "%s:error\n", path)
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			fmt.Fprintf(h, "%s:%d:%d\n", path, info.Size(), info.ModTime().UnixNano())
+			return nil
+		})
+	}
+	return h.Sum64()
+}
+
+// reloadScript reloads the preview page when the template changes
+var reloadScript = htmltemplate.Must(htmltemplate.New("reload").Parse(
+	`<script>new EventSource({{ . }}).addEventListener("reload", () => location.reload())</script>`,
+))
+
+// withReloadScript appends the reload script to the page
+func withReloadScript(page []byte, template string) []byte {
+	var script bytes.Buffer
+	_ = reloadScript.Execute(&script, fmt.Sprintf("/templates/%s/events", template))
+	return append(page, script.Bytes()...)
+}
+
+// errorOverlay displays an error of the template in dev mode
+var errorOverlay = htmltemplate.Must(htmltemplate.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Template error</title></head>
+<body style="margin: 0; background: #fff">
+<div id="httpdf-error-overlay" style="position: fixed; inset: 0; padding: 24px 32px; background: rgba(24, 24, 27, 0.92); color: #fafafa; font: 14px/1.5 sans-serif; overflow: auto">
+<h1 style="margin: 0 0 16px; color: #f87171; font-size: 20px">Failed to render template “{{ .Template }}”</h1>
+<pre style="margin: 0; white-space: pre-wrap; font: 13px/1.5 monospace">{{ .Error }}</pre>
+<p style="color: #a1a1aa">The page reloads automatically once the template changes.</p>
+</div>
+</body>
+</html>
+`))
+
+// previewError responds with the error, which is displayed in an overlay in
+// dev mode
+func (s *server) previewError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if s.devTemplates == nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	var page bytes.Buffer
+	_ = errorOverlay.Execute(&page, map[string]any{
+		"Template": r.PathValue("template"),
+		"Error":    err.Error(),
+	})
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(status)
+	_, _ = w.Write(withReloadScript(page.Bytes(), r.PathValue("template")))
+}
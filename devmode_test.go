@@ -0,0 +1,168 @@
+package httpdf_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sehrgutesoftware/httpdf"
+	"github.com/sehrgutesoftware/httpdf/internal/subdirfs"
+	"github.com/sehrgutesoftware/httpdf/internal/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// devServer serves a template from a temporary directory in dev mode
+func devServer(t *testing.T, content string) (http.Handler, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"hello/template.html": content,
+		"hello/config.yaml":   "page:\n  width: 210\n  height: 297\n",
+		"hello/schema.json":   `{"type": "object"}`,
+	}
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	templates := subdirfs.New(dir)
+	server := httpdf.NewServer(httpdf.New(&stubRenderer{}), template.NewFSLoader(templates), httpdf.WithDevMode(templates))
+	return server, filepath.Join(dir, "hello")
+}
+
+func TestServer_DevMode(t *testing.T) {
+	t.Run("it_injects_the_reload_script_into_the_preview", func(t *testing.T) {
+		server, _ := devServer(t, "<body>Hello</body>")
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/templates/hello/preview", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `<body>Hello</body><script>new EventSource("/templates/hello/events")`)
+	})
+
+	t.Run("it_shows_template_errors_in_an_overlay", func(t *testing.T) {
+		server, _ := devServer(t, "Hello {{ .name")
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/templates/hello/preview", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, "text/html", rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Body.String(), `id="httpdf-error-overlay"`)
+		assert.Contains(t, rec.Body.String(), "parse template")
+		assert.Contains(t, rec.Body.String(), "new EventSource(")
+	})
+
+	t.Run("it_sends_an_event_when_the_template_changes", func(t *testing.T) {
+		server, dir := devServer(t, "Hello")
+		srv := httptest.NewServer(server)
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/templates/hello/events", nil)
+		require.NoError(t, err)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+		// Wait until the stream is open before changing the template
+		lines := bufio.NewScanner(res.Body)
+		require.True(t, lines.Scan())
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "template.html"), []byte("Hello World"), 0o644))
+
+		var event string
+		for lines.Scan() {
+			if strings.HasPrefix(lines.Text(), "event: ") {
+				event = strings.TrimPrefix(lines.Text(), "event: ")
+				break
+			}
+		}
+		assert.Equal(t, "reload", event)
+	})
+
+	t.Run("it_sends_an_event_when_a_shared_partial_changes", func(t *testing.T) {
+		server, dir := devServer(t, "Hello")
+		srv := httptest.NewServer(server)
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/templates/hello/events", nil)
+		require.NoError(t, err)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		lines := bufio.NewScanner(res.Body)
+		require.True(t, lines.Scan())
+		partials := filepath.Join(filepath.Dir(dir), "_partials")
+		require.NoError(t, os.MkdirAll(partials, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(partials, "footer.html"), []byte(`{{ define "footer" }}Bye{{ end }}`), 0o644))
+
+		var event string
+		for lines.Scan() {
+			if strings.HasPrefix(lines.Text(), "event: ") {
+				event = strings.TrimPrefix(lines.Text(), "event: ")
+				break
+			}
+		}
+		assert.Equal(t, "reload", event)
+	})
+
+	t.Run("it_sends_an_event_when_a_base_template_changes", func(t *testing.T) {
+		server, dir := devServer(t, `{{ define "body" }}Hello{{ end }}`)
+		base := filepath.Join(filepath.Dir(dir), "base")
+		require.NoError(t, os.MkdirAll(base, 0o755))
+		for name, content := range map[string]string{
+			"template.html": `{{ block "body" . }}{{ end }}`,
+			"config.yaml":   "page:\n  width: 210\n  height: 297\n",
+			"schema.json":   `{"type": "object"}`,
+		} {
+			require.NoError(t, os.WriteFile(filepath.Join(base, name), []byte(content), 0o644))
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("extends: base\n"), 0o644))
+		srv := httptest.NewServer(server)
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/templates/hello/events", nil)
+		require.NoError(t, err)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		lines := bufio.NewScanner(res.Body)
+		require.True(t, lines.Scan())
+		require.NoError(t, os.WriteFile(filepath.Join(base, "template.html"), []byte(`<main>{{ block "body" . }}{{ end }}</main>`), 0o644))
+
+		var event string
+		for lines.Scan() {
+			if strings.HasPrefix(lines.Text(), "event: ") {
+				event = strings.TrimPrefix(lines.Text(), "event: ")
+				break
+			}
+		}
+		assert.Equal(t, "reload", event)
+	})
+
+	t.Run("it_is_disabled_by_default", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/templates/hello/events", nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -1,13 +1,16 @@
 package httpdf
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net"
 	"net/http"
+	"slices"
 
 	"github.com/sehrgutesoftware/httpdf/internal/pdf"
 	"github.com/sehrgutesoftware/httpdf/internal/template"
@@ -16,55 +19,345 @@ import (
 var (
 	// ErrInvalidValues is returned when the values are invalid
 	ErrInvalidValues = errors.New("invalid values")
+	// ErrUnknownPostProcessor is returned when a template selects a
+	// post-processor that is not registered
+	ErrUnknownPostProcessor = errors.New("unknown post-processor")
+	// ErrPostProcessorOrder is returned when a template selects
+	// post-processors to run after signing, which would invalidate the
+	// signature
+	ErrPostProcessorOrder = errors.New("invalid post-processor order")
+	// ErrUnknownRenderer is returned when a template selects a renderer that
+	// is not registered
+	ErrUnknownRenderer = errors.New("unknown renderer")
 )
 
 // HTTPDF is the interface for the httpdf service.
 type HTTPDF interface {
 	// Generate renders a PDF from the given template and values.
-	Generate(ctx context.Context, t *template.Template, locale string, v map[string]any, w io.Writer) error
+	Generate(ctx context.Context, t *template.Template, locale string, v map[string]any, w io.Writer, opts ...GenerateOption) error
+	// RenderHTML renders a PDF from raw HTML. The assets are served next to
+	// the page, so it can reference them by relative URLs.
+	RenderHTML(ctx context.Context, html []byte, assets fs.FS, opts pdf.RenderOpts, w io.Writer) error
+	// RenderURL renders a PDF of the page at the URL
+	RenderURL(ctx context.Context, url string, opts pdf.RenderOpts, w io.Writer) error
+	// Snapshot renders a PNG screenshot of the template with the given values,
+	// e.g. for visual regression tests
+	Snapshot(ctx context.Context, t *template.Template, locale string, v map[string]any, w io.Writer) error
+}
+
+// Job describes a single PDF generation. It is passed to the post-processors.
+type Job struct {
+	Template *template.Template
+	Locale   string
+	Values   map[string]any
+	// Encryption overrides the template's encryption config
+	Encryption *Encryption
+	// Watermarks are the names of the requested watermarks
+	Watermarks []string
+	// Diagnostics are the messages reported by the page while rendering
+	Diagnostics pdf.Diagnostics
+
+	onDiagnostics func(pdf.Diagnostics)
+}
+
+// GenerateOption customizes a single call to Generate
+type GenerateOption func(*Job)
+
+// Encryption contains the passwords and permissions used to encrypt a PDF.
+// Empty fields fall back to the template's encryption config.
+type Encryption struct {
+	UserPassword  string
+	OwnerPassword string
+	Permissions   []string
+}
+
+// WithEncryption encrypts the generated PDF, even if the template doesn't
+// configure encryption itself
+func WithEncryption(e Encryption) GenerateOption {
+	return func(j *Job) {
+		j.Encryption = &e
+	}
+}
+
+// WithWatermarks applies the named watermarks from the template's config to
+// the generated PDF. Names not configured by the template are used as text of
+// an ad-hoc watermark.
+func WithWatermarks(names ...string) GenerateOption {
+	return func(j *Job) {
+		j.Watermarks = append(j.Watermarks, names...)
+	}
+}
+
+// WithDiagnostics calls fn with the diagnostics reported by the page once it
+// has been rendered, before the PDF is written
+func WithDiagnostics(fn func(pdf.Diagnostics)) GenerateOption {
+	return func(j *Job) {
+		j.onDiagnostics = fn
+	}
+}
+
+// Option configures the httpdf service
+type Option func(*httpdf)
+
+// WithPostProcessor adds a post-processor to the chain applied to every
+// rendered PDF. It runs after the built-in watermark post-processor and
+// before encryption and signing, so it receives a document it can parse and
+// doesn't invalidate the signature.
+func WithPostProcessor(p PostProcessor) Option {
+	return func(h *httpdf) {
+		h.postProcessors = slices.Insert(h.postProcessors, len(h.postProcessors)-2, p)
+	}
+}
+
+// WithRenderer registers an additional renderer, which templates can select
+// by name using the renderer key in their config
+func WithRenderer(name string, r pdf.Renderer) Option {
+	return func(h *httpdf) {
+		h.renderers[name] = r
+	}
+}
+
+// WithAdvertisedHost sets the host name under which the browser reaches the
+// temporary server serving the rendered template. It's required if the
+// browser doesn't run on the same host, e.g. with a remote renderer in a
+// separate container. Defaults to the address the server listens on.
+func WithAdvertisedHost(host string) Option {
+	return func(h *httpdf) {
+		h.advertisedHost = host
+	}
 }
 
 // httpdf is the core implementation of the httpdf service.
 type httpdf struct {
-	pdfRenderer pdf.Renderer
+	pdfRenderer    pdf.Renderer
+	renderers      map[string]pdf.Renderer
+	postProcessors []PostProcessor
+	advertisedHost string
 }
 
-// New creates a new httpdf service.
+// New creates a new httpdf service. Templates are rendered using pdfRenderer,
+// unless they select another renderer registered using WithRenderer.
 func New(
 	pdfRenderer pdf.Renderer,
+	opts ...Option,
 ) HTTPDF {
-	return &httpdf{
+	h := &httpdf{
 		pdfRenderer: pdfRenderer,
+		renderers:   make(map[string]pdf.Renderer),
+		postProcessors: []PostProcessor{
+			&watermarkProcessor{},
+			&encryptionProcessor{},
+			&signatureProcessor{},
+		},
 	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
 }
 
 // Generate a PDF from the given template and values.
-func (h *httpdf) Generate(ctx context.Context, t *template.Template, locale string, v map[string]any, w io.Writer) error {
+func (h *httpdf) Generate(ctx context.Context, t *template.Template, locale string, v map[string]any, w io.Writer, opts ...GenerateOption) error {
+	job := &Job{
+		Template: t,
+		Locale:   locale,
+		Values:   v,
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+
+	if valid := t.Schema.Validate(v); !valid.Valid {
+		return fmt.Errorf("%w: %v", ErrInvalidValues, valid.Errors)
+	}
+	v = t.WithDefaults(v)
+	job.Values = v
+
+	chain, err := h.postProcessorChain(t)
+	if err != nil {
+		return fmt.Errorf("generate: %w", err)
+	}
+	if err := checkChain(chain, job); err != nil {
+		return fmt.Errorf("generate: %w", err)
+	}
+
+	// The post-processors need the complete document, so it is rendered into
+	// a buffer first.
+	rendered := &bytes.Buffer{}
+	job.Diagnostics, err = h.render(ctx, t, locale, v, pdf.FormatPDF, rendered)
+	if job.onDiagnostics != nil {
+		job.onDiagnostics(job.Diagnostics)
+	}
+	if err != nil {
+		return err
+	}
+
+	document := rendered.Bytes()
+	for _, p := range chain {
+		if document, err = p.Process(ctx, job, document); err != nil {
+			return fmt.Errorf("post-process PDF (%s): %w", p.Name(), err)
+		}
+	}
+
+	if _, err := w.Write(document); err != nil {
+		return fmt.Errorf("write PDF: %w", err)
+	}
+
+	return nil
+}
+
+// Snapshot renders a PNG screenshot of the template with the given values.
+// No post-processors are applied.
+func (h *httpdf) Snapshot(ctx context.Context, t *template.Template, locale string, v map[string]any, w io.Writer) error {
 	if valid := t.Schema.Validate(v); !valid.Valid {
 		return fmt.Errorf("%w: %v", ErrInvalidValues, valid.Errors)
 	}
 
+	_, err := h.render(ctx, t, locale, t.WithDefaults(v), pdf.FormatPNG, w)
+	return err
+}
+
+// render serves the template on a temporary server and renders it with the
+// renderer selected by the template
+func (h *httpdf) render(ctx context.Context, t *template.Template, locale string, v map[string]any, format pdf.Format, w io.Writer) (pdf.Diagnostics, error) {
+	renderer, err := h.renderer(t)
+	if err != nil {
+		return nil, fmt.Errorf("generate: %w", err)
+	}
+
+	// The page is rendered up front, so that template errors fail the
+	// render instead of being printed as error page
+	var page bytes.Buffer
+	if err := t.Render(v, "/assets", locale, &page); err != nil {
+		return nil, fmt.Errorf("render template: %w", err)
+	}
+
 	srvCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	serverAddr, err := h.temporaryServer(srvCtx, h.serve(t, locale, v))
+	serverAddr, err := h.temporaryServer(srvCtx, h.serve(t, page.Bytes()))
 	if err != nil {
-		return fmt.Errorf("generate: %w", err)
+		return nil, fmt.Errorf("generate: %w", err)
 	}
 
 	log.Printf("Starting temporary server at %s", serverAddr)
 
-	if err := h.pdfRenderer.Render(ctx, serverAddr, w, pdf.RenderOpts{
+	diagnostics, err := renderer.Render(ctx, serverAddr, w, pdf.RenderOpts{
+		Format:                  format,
 		Width:                   t.Config.Page.Width,
 		Height:                  t.Config.Page.Height,
 		GenerateTaggedPDF:       t.Config.PDF.GenerateTaggedPDF,
 		GenerateDocumentOutline: t.Config.PDF.GenerateDocumentOutline,
-	}); err != nil {
+		Wait:                    waitConditions(t.Config.Wait),
+		Network:                 pdf.NetworkPolicy{Allow: t.Config.Network.Allow},
+		Strict:                  t.Config.Strict,
+	})
+	for _, d := range diagnostics {
+		log.Printf("Page diagnostic: %s", d)
+	}
+	if err != nil {
+		return diagnostics, fmt.Errorf("render PDF: %w", err)
+	}
+
+	return diagnostics, nil
+}
+
+// RenderHTML renders a PDF from raw HTML using the default renderer. No
+// post-processors are applied, as they are configured by templates.
+func (h *httpdf) RenderHTML(ctx context.Context, html []byte, assets fs.FS, opts pdf.RenderOpts, w io.Writer) error {
+	mux := http.NewServeMux()
+	if assets != nil {
+		mux.Handle("GET /", http.FileServer(http.FS(assets)))
+	}
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write(html)
+	})
+
+	srvCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	serverAddr, err := h.temporaryServer(srvCtx, mux)
+	if err != nil {
+		return fmt.Errorf("render HTML: %w", err)
+	}
+
+	log.Printf("Starting temporary server at %s", serverAddr)
+
+	return h.RenderURL(ctx, serverAddr, opts, w)
+}
+
+// RenderURL renders a PDF of the page at the URL using the default renderer.
+// No post-processors are applied, as they are configured by templates.
+func (h *httpdf) RenderURL(ctx context.Context, url string, opts pdf.RenderOpts, w io.Writer) error {
+	diagnostics, err := h.pdfRenderer.Render(ctx, url, w, opts)
+	for _, d := range diagnostics {
+		log.Printf("Page diagnostic: %s", d)
+	}
+	if err != nil {
 		return fmt.Errorf("render PDF: %w", err)
 	}
+	return nil
+}
+
+// postProcessorChain returns the post-processors to apply to the template. If
+// the template selects post-processors in its config, only those are applied,
+// in the given order, and sign must be the last one. Otherwise, all
+// post-processors are applied.
+func (h *httpdf) postProcessorChain(t *template.Template) ([]PostProcessor, error) {
+	if t.Config.PostProcessors == nil {
+		return h.postProcessors, nil
+	}
+
+	chain := make([]PostProcessor, 0, len(t.Config.PostProcessors))
+	for _, name := range t.Config.PostProcessors {
+		i := slices.IndexFunc(h.postProcessors, func(p PostProcessor) bool {
+			return p.Name() == name
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownPostProcessor, name)
+		}
+		chain = append(chain, h.postProcessors[i])
+	}
+	if i := slices.Index(t.Config.PostProcessors, "sign"); i >= 0 && i < len(t.Config.PostProcessors)-1 {
+		return nil, fmt.Errorf("%w: %s runs after sign, which invalidates the signature", ErrPostProcessorOrder, t.Config.PostProcessors[i+1])
+	}
 
+	return chain, nil
+}
+
+// checkChain reports conflicts between the post-processors of the chain
+// before the job is rendered, which would otherwise only fail afterwards
+func checkChain(chain []PostProcessor, job *Job) error {
+	names := make([]string, len(chain))
+	for i, p := range chain {
+		names[i] = p.Name()
+	}
+	if job.Template.Config.Signature == nil || !slices.Contains(names, "sign") || !slices.Contains(names, "encrypt") {
+		return nil
+	}
+	encryption, err := encryptOpts(job.Template, job.Locale, job.Values, job.Encryption)
+	if err != nil {
+		return fmt.Errorf("encryption: %w", err)
+	}
+	if encryption != nil {
+		return pdf.ErrEncryptedDocument
+	}
 	return nil
 }
 
+// renderer returns the renderer selected by the template, or the default one
+func (h *httpdf) renderer(t *template.Template) (pdf.Renderer, error) {
+	if t.Config.Renderer == "" {
+		return h.pdfRenderer, nil
+	}
+	r, ok := h.renderers[t.Config.Renderer]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownRenderer, t.Config.Renderer)
+	}
+	return r, nil
+}
+
 func (h *httpdf) temporaryServer(ctx context.Context, handler http.Handler) (string, error) {
 	// Assign a random free local TCP port for the temporary server
 	listener, err := net.Listen("tcp", ":0")
@@ -91,20 +384,42 @@ func (h *httpdf) temporaryServer(ctx context.Context, handler http.Handler) (str
 		}
 	}()
 
-	return fmt.Sprintf("http://%s", listener.Addr().String()), nil
+	addr := listener.Addr().String()
+	if h.advertisedHost != "" {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return "", fmt.Errorf("temporary server: %w", err)
+		}
+		addr = net.JoinHostPort(h.advertisedHost, port)
+	}
+
+	return fmt.Sprintf("http://%s", addr), nil
 }
 
-func (h *httpdf) serve(t *template.Template, locale string, v map[string]any) http.Handler {
+// serve serves the rendered page along with the template's assets
+func (h *httpdf) serve(t *template.Template, page []byte) http.Handler {
 	mux := http.NewServeMux()
 	mux.Handle("GET /assets/", http.StripPrefix("/assets/", http.FileServer(http.FS(t.Assets))))
 	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html")
-		err := t.Render(v, "/assets", locale, w)
-		if err != nil {
-			http.Error(w, "render template", http.StatusInternalServerError)
-			return
-		}
+		_, _ = w.Write(page)
 	})
 
 	return mux
 }
+
+// waitConditions converts the template's wait config for the renderer
+func waitConditions(config []template.WaitConfig) []pdf.Wait {
+	conditions := make([]pdf.Wait, len(config))
+	for i, c := range config {
+		conditions[i] = pdf.Wait{
+			Selector:    c.Selector,
+			Expression:  c.Expression,
+			Fonts:       c.Fonts,
+			NetworkIdle: c.NetworkIdle,
+			Delay:       c.Delay,
+			Timeout:     c.Timeout,
+		}
+	}
+	return conditions
+}
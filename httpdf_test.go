@@ -0,0 +1,323 @@
+package httpdf_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kaptinlin/jsonschema"
+	"github.com/sehrgutesoftware/httpdf"
+	"github.com/sehrgutesoftware/httpdf/internal/pdf"
+	"github.com/sehrgutesoftware/httpdf/internal/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubRenderer fetches the page like a browser would, and returns a fixed
+// document instead of printing the page
+type stubRenderer struct {
+	url         string
+	html        string
+	diagnostics pdf.Diagnostics
+	// asset is fetched relative to the page, its content is stored in fetched
+	asset   string
+	fetched string
+}
+
+func (r *stubRenderer) Render(ctx context.Context, url string, w io.Writer, opts pdf.RenderOpts) (pdf.Diagnostics, error) {
+	r.url = url
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	html, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.html = string(html)
+
+	if r.asset != "" {
+		res, err := http.Get(url + "/" + r.asset)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		asset, err := io.ReadAll(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		r.fetched = string(asset)
+	}
+
+	if opts.Strict {
+		if err := r.diagnostics.Err(); err != nil {
+			return r.diagnostics, err
+		}
+	}
+	_, err = w.Write([]byte("%PDF"))
+	return r.diagnostics, err
+}
+
+// suffixProcessor appends its name to the document, or fails with err
+type suffixProcessor struct {
+	name string
+	err  error
+}
+
+func (p *suffixProcessor) Name() string {
+	return p.name
+}
+
+func (p *suffixProcessor) Process(ctx context.Context, job *httpdf.Job, document []byte) ([]byte, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return append(document, []byte("|"+p.name)...), nil
+}
+
+func testTemplate(t *testing.T, content string) *template.Template {
+	t.Helper()
+
+	schema, err := jsonschema.NewCompiler().Compile([]byte(`{
+  "type": "object",
+  "properties": {"name": {"type": "string"}}
+}`))
+	require.NoError(t, err)
+
+	tmpl := &template.Template{Schema: schema}
+	tmpl.WriteString(content)
+	return tmpl
+}
+
+func TestGenerate(t *testing.T) {
+	t.Run("it_renders_the_template_with_the_values", func(t *testing.T) {
+		renderer := &stubRenderer{}
+		app := httpdf.New(renderer)
+
+		var out bytes.Buffer
+		err := app.Generate(context.Background(), testTemplate(t, "Hello {{ .name }}!"), "en", map[string]any{"name": "World"}, &out)
+
+		require.NoError(t, err)
+		assert.Equal(t, "Hello World!", renderer.html)
+		assert.Equal(t, "%PDF", out.String())
+	})
+
+	t.Run("it_returns_an_error_for_invalid_values", func(t *testing.T) {
+		app := httpdf.New(&stubRenderer{})
+
+		err := app.Generate(context.Background(), testTemplate(t, ""), "en", map[string]any{"name": 42}, &bytes.Buffer{})
+
+		assert.ErrorIs(t, err, httpdf.ErrInvalidValues)
+	})
+
+	t.Run("it_applies_the_defaults_of_the_schema_if_enabled", func(t *testing.T) {
+		schema, err := jsonschema.NewCompiler().Compile([]byte(`{
+  "type": "object",
+  "properties": {"name": {"type": "string", "default": "World"}}
+}`))
+		require.NoError(t, err)
+		tmpl := &template.Template{Schema: schema}
+		tmpl.Config.ApplyDefaults = true
+		tmpl.WriteString("Hello {{ .name }}!")
+		renderer := &stubRenderer{}
+
+		err = httpdf.New(renderer).Generate(context.Background(), tmpl, "en", map[string]any{}, &bytes.Buffer{})
+
+		require.NoError(t, err)
+		assert.Equal(t, "Hello World!", renderer.html)
+	})
+
+	t.Run("it_fails_without_rendering_for_missing_keys_in_error_mode", func(t *testing.T) {
+		tmpl := testTemplate(t, "Hello {{ .name }}!")
+		tmpl.Config.MissingKey = "error"
+		renderer := &stubRenderer{}
+
+		err := httpdf.New(renderer).Generate(context.Background(), tmpl, "en", map[string]any{}, &bytes.Buffer{})
+
+		assert.ErrorIs(t, err, template.ErrMissingKey)
+		assert.Empty(t, renderer.url)
+	})
+
+	t.Run("it_applies_post_processors_in_order", func(t *testing.T) {
+		app := httpdf.New(&stubRenderer{},
+			httpdf.WithPostProcessor(&suffixProcessor{name: "first"}),
+			httpdf.WithPostProcessor(&suffixProcessor{name: "second"}),
+		)
+
+		var out bytes.Buffer
+		err := app.Generate(context.Background(), testTemplate(t, ""), "en", map[string]any{}, &out)
+
+		require.NoError(t, err)
+		assert.Equal(t, "%PDF|first|second", out.String())
+	})
+
+	t.Run("it_applies_the_post_processors_selected_by_the_template", func(t *testing.T) {
+		app := httpdf.New(&stubRenderer{},
+			httpdf.WithPostProcessor(&suffixProcessor{name: "first"}),
+			httpdf.WithPostProcessor(&suffixProcessor{name: "second"}),
+		)
+		tmpl := testTemplate(t, "")
+		tmpl.Config.PostProcessors = []string{"second", "first"}
+
+		var out bytes.Buffer
+		err := app.Generate(context.Background(), tmpl, "en", map[string]any{}, &out)
+
+		require.NoError(t, err)
+		assert.Equal(t, "%PDF|second|first", out.String())
+	})
+
+	t.Run("it_applies_no_post_processors_if_the_template_selects_none", func(t *testing.T) {
+		app := httpdf.New(&stubRenderer{}, httpdf.WithPostProcessor(&suffixProcessor{name: "first"}))
+		tmpl := testTemplate(t, "")
+		tmpl.Config.PostProcessors = []string{}
+
+		var out bytes.Buffer
+		err := app.Generate(context.Background(), tmpl, "en", map[string]any{}, &out)
+
+		require.NoError(t, err)
+		assert.Equal(t, "%PDF", out.String())
+	})
+
+	t.Run("it_returns_an_error_for_unknown_post_processors", func(t *testing.T) {
+		app := httpdf.New(&stubRenderer{})
+		tmpl := testTemplate(t, "")
+		tmpl.Config.PostProcessors = []string{"compress"}
+
+		err := app.Generate(context.Background(), tmpl, "en", map[string]any{}, &bytes.Buffer{})
+
+		assert.ErrorIs(t, err, httpdf.ErrUnknownPostProcessor)
+	})
+
+	t.Run("it_returns_an_error_for_post_processors_after_sign", func(t *testing.T) {
+		renderer := &stubRenderer{}
+		tmpl := testTemplate(t, "")
+		tmpl.Config.PostProcessors = []string{"sign", "watermark"}
+
+		err := httpdf.New(renderer).Generate(context.Background(), tmpl, "en", map[string]any{}, &bytes.Buffer{})
+
+		assert.ErrorIs(t, err, httpdf.ErrPostProcessorOrder)
+		assert.Empty(t, renderer.url)
+	})
+
+	t.Run("it_returns_an_error_for_encryption_and_signature_without_rendering", func(t *testing.T) {
+		renderer := &stubRenderer{}
+		tmpl := testTemplate(t, "")
+		tmpl.Config.Signature = &template.SignatureConfig{PKCS12: "signing.p12"}
+
+		err := httpdf.New(renderer).Generate(context.Background(), tmpl, "en", map[string]any{}, &bytes.Buffer{},
+			httpdf.WithEncryption(httpdf.Encryption{UserPassword: "secret"}),
+		)
+
+		assert.ErrorIs(t, err, pdf.ErrEncryptedDocument)
+		assert.Empty(t, renderer.url)
+	})
+
+	t.Run("it_signs_after_added_post_processors", func(t *testing.T) {
+		failed := errors.New("failed")
+		app := httpdf.New(&stubRenderer{}, httpdf.WithPostProcessor(&suffixProcessor{name: "first", err: failed}))
+		tmpl := testTemplate(t, "")
+		// Signing fails without credentials, so the error tells which
+		// post-processor ran first
+		tmpl.Config.Signature = &template.SignatureConfig{}
+
+		err := app.Generate(context.Background(), tmpl, "en", map[string]any{}, &bytes.Buffer{})
+
+		assert.ErrorIs(t, err, failed)
+	})
+
+	t.Run("it_applies_added_post_processors_before_encryption", func(t *testing.T) {
+		failed := errors.New("failed")
+		app := httpdf.New(&stubRenderer{}, httpdf.WithPostProcessor(&suffixProcessor{name: "first", err: failed}))
+
+		// Encrypting fails for the stub document, so the error tells which
+		// post-processor ran first
+		err := app.Generate(context.Background(), testTemplate(t, ""), "en", map[string]any{}, &bytes.Buffer{},
+			httpdf.WithEncryption(httpdf.Encryption{UserPassword: "secret"}),
+		)
+
+		assert.ErrorIs(t, err, failed)
+	})
+
+	t.Run("it_reports_the_diagnostics_of_the_page", func(t *testing.T) {
+		diagnostics := pdf.Diagnostics{
+			{Kind: pdf.DiagnosticConsole, Level: "log", Message: "chart rendered"},
+		}
+		app := httpdf.New(&stubRenderer{diagnostics: diagnostics})
+
+		var reported pdf.Diagnostics
+		err := app.Generate(context.Background(), testTemplate(t, ""), "en", map[string]any{}, &bytes.Buffer{},
+			httpdf.WithDiagnostics(func(d pdf.Diagnostics) { reported = d }),
+		)
+
+		require.NoError(t, err)
+		assert.Equal(t, diagnostics, reported)
+	})
+
+	t.Run("it_fails_on_page_errors_in_strict_mode", func(t *testing.T) {
+		app := httpdf.New(&stubRenderer{diagnostics: pdf.Diagnostics{
+			{Kind: pdf.DiagnosticRequest, Message: "HTTP 404", URL: "http://localhost/assets/logo.png"},
+		}})
+		tmpl := testTemplate(t, "")
+		tmpl.Config.Strict = true
+
+		err := app.Generate(context.Background(), tmpl, "en", map[string]any{}, &bytes.Buffer{})
+
+		assert.ErrorIs(t, err, pdf.ErrPageErrors)
+	})
+
+	t.Run("it_serves_the_page_under_the_advertised_host", func(t *testing.T) {
+		renderer := &stubRenderer{}
+		app := httpdf.New(renderer, httpdf.WithAdvertisedHost("localhost"))
+
+		err := app.Generate(context.Background(), testTemplate(t, "Hello!"), "en", map[string]any{}, &bytes.Buffer{})
+
+		require.NoError(t, err)
+		assert.Regexp(t, `^http://localhost:\d+$`, renderer.url)
+		assert.Equal(t, "Hello!", renderer.html)
+	})
+
+	t.Run("it_renders_with_the_renderer_selected_by_the_template", func(t *testing.T) {
+		defaultRenderer := &stubRenderer{}
+		firefox := &stubRenderer{}
+		app := httpdf.New(defaultRenderer, httpdf.WithRenderer("firefox", firefox))
+		tmpl := testTemplate(t, "Hello!")
+		tmpl.Config.Renderer = "firefox"
+
+		err := app.Generate(context.Background(), tmpl, "en", map[string]any{}, &bytes.Buffer{})
+
+		require.NoError(t, err)
+		assert.Equal(t, "Hello!", firefox.html)
+		assert.Empty(t, defaultRenderer.html)
+	})
+
+	t.Run("it_returns_an_error_for_unknown_renderers", func(t *testing.T) {
+		app := httpdf.New(&stubRenderer{})
+		tmpl := testTemplate(t, "")
+		tmpl.Config.Renderer = "netscape"
+
+		err := app.Generate(context.Background(), tmpl, "en", map[string]any{}, &bytes.Buffer{})
+
+		assert.ErrorIs(t, err, httpdf.ErrUnknownRenderer)
+	})
+}
+
+func TestRenderHTML(t *testing.T) {
+	t.Run("it_renders_the_html_and_serves_the_assets", func(t *testing.T) {
+		renderer := &stubRenderer{asset: "img/logo.svg"}
+		app := httpdf.New(renderer)
+		assets := fstest.MapFS{"img/logo.svg": &fstest.MapFile{Data: []byte("<svg/>")}}
+
+		var out bytes.Buffer
+		err := app.RenderHTML(context.Background(), []byte("<h1>Hello</h1>"), assets, pdf.RenderOpts{Width: 210, Height: 297}, &out)
+
+		require.NoError(t, err)
+		assert.Equal(t, "<h1>Hello</h1>", renderer.html)
+		assert.Equal(t, "<svg/>", renderer.fetched)
+		assert.Equal(t, "%PDF", out.String())
+	})
+}
@@ -0,0 +1,157 @@
+package pdf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/coder/websocket"
+)
+
+var (
+	// errConnectionClosed is returned for commands pending when the
+	// connection to the browser is closed
+	errConnectionClosed = errors.New("connection closed")
+)
+
+// bidiConn is a minimal WebDriver BiDi client, see
+// https://w3c.github.io/webdriver-bidi/
+type bidiConn struct {
+	ws *websocket.Conn
+	// onEvent is called for each event, from the goroutine reading the
+	// connection. It must not block on commands.
+	onEvent func(method string, params json.RawMessage)
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan bidiMessage
+	err     error
+}
+
+// bidiMessage is a command response, error or event sent by the browser
+type bidiMessage struct {
+	Type    string          `json:"type"`
+	ID      int             `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	Result  json.RawMessage `json:"result"`
+	Error   string          `json:"error"`
+	Message string          `json:"message"`
+}
+
+// dialBiDi connects to the WebDriver BiDi endpoint at url
+func dialBiDi(ctx context.Context, url string, onEvent func(method string, params json.RawMessage)) (*bidiConn, error) {
+	ws, _, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Printed PDFs are returned as a single message
+	ws.SetReadLimit(-1)
+
+	c := &bidiConn{
+		ws:      ws,
+		onEvent: onEvent,
+		pending: make(map[int]chan bidiMessage),
+	}
+	go c.read()
+
+	return c, nil
+}
+
+// read dispatches the messages sent by the browser until the connection is
+// closed
+func (c *bidiConn) read() {
+	for {
+		_, data, err := c.ws.Read(context.Background())
+		if err != nil {
+			c.mu.Lock()
+			c.err = fmt.Errorf("%w: %w", errConnectionClosed, err)
+			for _, ch := range c.pending {
+				close(ch)
+			}
+			c.pending = nil
+			c.mu.Unlock()
+			return
+		}
+
+		var msg bidiMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+
+		switch msg.Type {
+		case "event":
+			if c.onEvent != nil {
+				c.onEvent(msg.Method, msg.Params)
+			}
+		case "success", "error":
+			c.mu.Lock()
+			ch, ok := c.pending[msg.ID]
+			delete(c.pending, msg.ID)
+			c.mu.Unlock()
+			if ok {
+				ch <- msg
+			}
+		}
+	}
+}
+
+// call sends a command and decodes its result into result, unless it's nil
+func (c *bidiConn) call(ctx context.Context, method string, params any, result any) error {
+	if params == nil {
+		params = struct{}{}
+	}
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan bidiMessage, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	data, err := json.Marshal(map[string]any{"id": id, "method": method, "params": params})
+	if err == nil {
+		err = c.ws.Write(ctx, websocket.MessageText, data)
+	}
+	if err != nil {
+		c.forget(id)
+		return fmt.Errorf("%s: %w", method, err)
+	}
+
+	select {
+	case msg, ok := <-ch:
+		if !ok {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return fmt.Errorf("%s: %w", method, c.err)
+		}
+		if msg.Type == "error" {
+			return fmt.Errorf("%s: %s: %s", method, msg.Error, msg.Message)
+		}
+		if result != nil {
+			return json.Unmarshal(msg.Result, result)
+		}
+		return nil
+	case <-ctx.Done():
+		c.forget(id)
+		return fmt.Errorf("%s: %w", method, ctx.Err())
+	}
+}
+
+// forget removes a pending command
+func (c *bidiConn) forget(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, id)
+}
+
+// close closes the connection
+func (c *bidiConn) close() error {
+	return c.ws.Close(websocket.StatusNormalClosure, "")
+}
@@ -0,0 +1,183 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+var (
+	oidData                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidSHA256               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSAEncryption        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSAWithSHA256      = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo contentInfo
+	Certificates     asn1.RawValue
+	SignerInfos      []signerInfo `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                issuerAndSerial
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+}
+
+type issuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+type essCertIDv2 struct {
+	CertHash     []byte
+	IssuerSerial issuerSerial
+}
+
+type issuerSerial struct {
+	Issuer       []asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// cmsSignDetached creates a detached CMS SignedData structure over the given
+// digest, carrying the signed attributes required by PAdES baseline
+// signatures (content-type, message-digest and signing-certificate-v2).
+func cmsSignDetached(digest []byte, creds *Credentials) ([]byte, error) {
+	sigAlg, err := signatureAlgorithm(creds.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	certHash := sha256.Sum256(creds.Certificate.Raw)
+	signingCert, err := asn1.Marshal(struct{ Certs []essCertIDv2 }{
+		Certs: []essCertIDv2{{
+			CertHash: certHash[:],
+			IssuerSerial: issuerSerial{
+				Issuer: []asn1.RawValue{{
+					Class:      asn1.ClassContextSpecific,
+					Tag:        4,
+					IsCompound: true,
+					Bytes:      creds.Certificate.RawIssuer,
+				}},
+				SerialNumber: creds.Certificate.SerialNumber,
+			},
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encode signing certificate: %w", err)
+	}
+
+	contentType, _ := asn1.Marshal(oidData)
+	messageDigest, _ := asn1.Marshal(digest)
+	signedAttrs, err := marshalAttributes([]attribute{
+		{Type: oidContentType, Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: contentType}},
+		{Type: oidMessageDigest, Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: messageDigest}},
+		{Type: oidSigningCertificateV2, Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: signingCert}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encode signed attributes: %w", err)
+	}
+
+	// The signature is computed over the DER encoding of the attributes as
+	// a SET, while they are embedded as [0] IMPLICIT in the signer info.
+	attrsSet, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: signedAttrs})
+	if err != nil {
+		return nil, fmt.Errorf("encode signed attributes: %w", err)
+	}
+	attrsDigest := sha256.Sum256(attrsSet)
+	signature, err := creds.Key.Sign(rand.Reader, attrsDigest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("sign attributes: %w", err)
+	}
+
+	var certs []byte
+	for _, cert := range append([]*x509.Certificate{creds.Certificate}, creds.Chain...) {
+		certs = append(certs, cert.Raw...)
+	}
+
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		EncapContentInfo: contentInfo{ContentType: oidData},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos: []signerInfo{{
+			Version: 1,
+			SID: issuerAndSerial{
+				Issuer:       asn1.RawValue{FullBytes: creds.Certificate.RawIssuer},
+				SerialNumber: creds.Certificate.SerialNumber,
+			},
+			DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedAttrs},
+			SignatureAlgorithm: sigAlg,
+			Signature:          signature,
+		}},
+	}
+	inner, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, fmt.Errorf("encode signed data: %w", err)
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: inner},
+	})
+}
+
+// marshalAttributes encodes the attributes as the content of a DER SET OF,
+// which requires the encoded elements to be sorted.
+func marshalAttributes(attrs []attribute) ([]byte, error) {
+	encoded := make([][]byte, len(attrs))
+	for i, attr := range attrs {
+		b, err := asn1.Marshal(attr)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = b
+	}
+	sort.Slice(encoded, func(i, j int) bool {
+		return bytes.Compare(encoded[i], encoded[j]) < 0
+	})
+
+	return bytes.Join(encoded, nil), nil
+}
+
+func signatureAlgorithm(key crypto.Signer) (pkix.AlgorithmIdentifier, error) {
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		return pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue}, nil
+	case *ecdsa.PublicKey:
+		return pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}, nil
+	default:
+		return pkix.AlgorithmIdentifier{}, errors.New("unsupported private key type")
+	}
+}
@@ -0,0 +1,270 @@
+package pdf_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/sehrgutesoftware/httpdf/internal/pdf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance runs the same suite against every browser backend. Backends
+// whose browser isn't installed are skipped; the binaries are looked up in
+// PATH or set using HTTPDF_TEST_CHROMIUM and HTTPDF_TEST_FIREFOX.
+func TestConformance(t *testing.T) {
+	backends := []struct {
+		name   string
+		env    string
+		binary []string
+		new    func(string) pdf.Renderer
+	}{
+		{"chromium", "HTTPDF_TEST_CHROMIUM", []string{"chromium", "chromium-browser", "google-chrome"}, pdf.NewRodRenderer},
+		{"firefox", "HTTPDF_TEST_FIREFOX", []string{"firefox"}, pdf.NewFirefoxRenderer},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			binary := browserBinary(backend.env, backend.binary)
+			if binary == "" {
+				t.Skipf("%s is not installed", backend.name)
+			}
+			testRendererConformance(t, backend.new(binary))
+		})
+	}
+}
+
+// TestNetworkConformance runs the network policy part of the suite against
+// the native renderer, which doesn't run scripts
+func TestNetworkConformance(t *testing.T) {
+	testNetworkConformance(t, pdf.NewNativeRenderer())
+}
+
+// browserBinary returns the path of the browser binary, or an empty string
+// if it's not installed
+func browserBinary(env string, names []string) string {
+	if path := os.Getenv(env); path != "" {
+		return path
+	}
+	for _, name := range names {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// servePages serves the HTML pages by path
+func servePages(t *testing.T, pages map[string]string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(page))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// testRendererConformance checks that the renderer implements the semantics
+// of RenderOpts
+func testRendererConformance(t *testing.T, r pdf.Renderer) {
+	ctx := context.Background()
+	a4 := pdf.RenderOpts{Width: 210, Height: 297}
+
+	t.Run("it_renders_a_pdf_of_the_given_page_size", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><body>Hello</body></html>`})
+
+		var out bytes.Buffer
+		_, err := r.Render(ctx, srv.URL+"/", &out, a4)
+
+		require.NoError(t, err)
+		dims, err := api.PageDims(bytes.NewReader(out.Bytes()), nil)
+		require.NoError(t, err)
+		require.Len(t, dims, 1)
+		assert.InDelta(t, 595.3, dims[0].Width, 1)
+		assert.InDelta(t, 841.9, dims[0].Height, 1)
+	})
+
+	t.Run("it_renders_a_png_screenshot_of_the_page_width", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><body>Hello</body></html>`})
+		opts := a4
+		opts.Format = pdf.FormatPNG
+
+		var out bytes.Buffer
+		_, err := r.Render(ctx, srv.URL+"/", &out, opts)
+
+		require.NoError(t, err)
+		img, err := png.Decode(&out)
+		require.NoError(t, err)
+		assert.Equal(t, 794, img.Bounds().Dx())
+	})
+
+	t.Run("it_waits_for_a_selector", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><body><script>
+			setTimeout(() => document.body.innerHTML = '<div id="chart"></div>', 300)
+		</script></body></html>`})
+		opts := a4
+		opts.Wait = []pdf.Wait{{Selector: "#chart", Timeout: 5 * time.Second}}
+
+		_, err := r.Render(ctx, srv.URL+"/", &bytes.Buffer{}, opts)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("it_waits_for_an_expression_until_the_timeout", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><body></body></html>`})
+		opts := a4
+		opts.Wait = []pdf.Wait{{Expression: "window.httpdfReady === true", Timeout: 300 * time.Millisecond}}
+
+		_, err := r.Render(ctx, srv.URL+"/", &bytes.Buffer{}, opts)
+
+		assert.ErrorContains(t, err, "window.httpdfReady")
+	})
+
+	testNetworkConformance(t, r)
+
+	t.Run("it_blocks_websockets_outside_the_network_policy", func(t *testing.T) {
+		var hits atomic.Int32
+		external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+		}))
+		t.Cleanup(external.Close)
+		srv := servePages(t, map[string]string{"/": `<html><body><script>
+			new WebSocket("` + strings.Replace(external.URL, "http", "ws", 1) + `/socket")
+		</script></body></html>`})
+
+		_, err := r.Render(ctx, srv.URL+"/", &bytes.Buffer{}, a4)
+
+		// Browsers that can't intercept WebSocket connections fail the
+		// render instead
+		if !errors.Is(err, pdf.ErrBlockedWebSocket) {
+			require.NoError(t, err)
+			assert.Zero(t, hits.Load())
+		}
+	})
+
+	t.Run("it_allows_blob_urls_of_the_page", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><body><script>
+			const blob = new Blob(["<svg xmlns='http://www.w3.org/2000/svg'/>"], {type: "image/svg+xml"})
+			const img = document.createElement("img")
+			img.src = URL.createObjectURL(blob)
+			document.body.appendChild(img)
+		</script></body></html>`})
+
+		diagnostics, err := r.Render(ctx, srv.URL+"/", &bytes.Buffer{}, a4)
+
+		require.NoError(t, err)
+		assert.Empty(t, diagnostics.Errors())
+	})
+
+	t.Run("it_reports_console_messages_and_exceptions", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><body><script>
+			console.log("rendering chart");
+			throw new Error("chart failed");
+		</script></body></html>`})
+
+		diagnostics, err := r.Render(ctx, srv.URL+"/", &bytes.Buffer{}, a4)
+
+		require.NoError(t, err)
+		var console, exceptions []string
+		for _, d := range diagnostics {
+			switch d.Kind {
+			case pdf.DiagnosticConsole:
+				console = append(console, d.Message)
+			case pdf.DiagnosticException:
+				exceptions = append(exceptions, d.Message)
+			}
+		}
+		assert.Contains(t, console, "rendering chart")
+		require.Len(t, exceptions, 1)
+		assert.Contains(t, exceptions[0], "chart failed")
+	})
+
+	t.Run("it_fails_on_missing_assets_in_strict_mode", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><body><img src="/missing.png"></body></html>`})
+		opts := a4
+		opts.Strict = true
+
+		_, err := r.Render(ctx, srv.URL+"/", &bytes.Buffer{}, opts)
+
+		assert.ErrorIs(t, err, pdf.ErrPageErrors)
+	})
+}
+
+// testNetworkConformance checks that the renderer enforces the network policy
+// of RenderOpts
+func testNetworkConformance(t *testing.T, r pdf.Renderer) {
+	ctx := context.Background()
+	a4 := pdf.RenderOpts{Width: 210, Height: 297}
+
+	t.Run("it_blocks_requests_outside_the_network_policy", func(t *testing.T) {
+		var hits atomic.Int32
+		external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+		}))
+		t.Cleanup(external.Close)
+		srv := servePages(t, map[string]string{"/": `<html><body><img src="` + external.URL + `/pixel.png"></body></html>`})
+
+		diagnostics, err := r.Render(ctx, srv.URL+"/", &bytes.Buffer{}, a4)
+
+		require.NoError(t, err)
+		assert.Zero(t, hits.Load())
+		assert.NotEmpty(t, diagnostics.Errors())
+	})
+
+	t.Run("it_allows_requests_from_the_allowlist", func(t *testing.T) {
+		var hits atomic.Int32
+		external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			// The native renderer fails on images it can't decode
+			data, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(pngPixel, "data:image/png;base64,"))
+			_, _ = w.Write(data)
+		}))
+		t.Cleanup(external.Close)
+		srv := servePages(t, map[string]string{"/": `<html><body><img src="` + external.URL + `/pixel.png"></body></html>`})
+		opts := a4
+		opts.Network = pdf.NetworkPolicy{Allow: []string{external.URL + "/*"}}
+
+		_, err := r.Render(ctx, srv.URL+"/", &bytes.Buffer{}, opts)
+
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), hits.Load())
+	})
+
+	t.Run("it_blocks_redirects_outside_the_network_policy", func(t *testing.T) {
+		var hits atomic.Int32
+		blocked := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+		}))
+		t.Cleanup(blocked.Close)
+		allowed := httptest.NewServer(http.RedirectHandler(blocked.URL+"/pixel.png", http.StatusFound))
+		t.Cleanup(allowed.Close)
+		srv := servePages(t, map[string]string{"/": `<html><body><img src="` + allowed.URL + `/pixel.png"></body></html>`})
+		opts := a4
+		opts.Network = pdf.NetworkPolicy{Allow: []string{allowed.URL + "/*"}}
+
+		diagnostics, err := r.Render(ctx, srv.URL+"/", &bytes.Buffer{}, opts)
+
+		require.NoError(t, err)
+		assert.Zero(t, hits.Load())
+		assert.NotEmpty(t, diagnostics.Errors())
+	})
+}
@@ -0,0 +1,161 @@
+package pdf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+var (
+	// ErrPageErrors is returned in strict mode if the page threw an exception
+	// or failed to load a resource
+	ErrPageErrors = errors.New("page reported errors")
+)
+
+// DiagnosticKind is the source of a Diagnostic
+type DiagnosticKind string
+
+const (
+	// DiagnosticConsole is a message logged to the browser console
+	DiagnosticConsole DiagnosticKind = "console"
+	// DiagnosticException is an uncaught JS exception
+	DiagnosticException DiagnosticKind = "exception"
+	// DiagnosticRequest is a failed or blocked request for a resource
+	DiagnosticRequest DiagnosticKind = "request"
+)
+
+// Diagnostic is a message reported by the page while rendering
+type Diagnostic struct {
+	Kind DiagnosticKind `json:"kind"`
+	// Level of console messages, e.g. log, warning or error
+	Level   string `json:"level,omitempty"`
+	Message string `json:"message"`
+	// URL of the failed request or the script that threw the exception
+	URL string `json:"url,omitempty"`
+}
+
+// IsError reports whether the diagnostic indicates a broken document, i.e. an
+// uncaught exception or a failed request
+func (d Diagnostic) IsError() bool {
+	return d.Kind == DiagnosticException || d.Kind == DiagnosticRequest
+}
+
+// String formats the diagnostic for logging
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	b.WriteString(string(d.Kind))
+	if d.Level != "" {
+		fmt.Fprintf(&b, " (%s)", d.Level)
+	}
+	fmt.Fprintf(&b, ": %s", d.Message)
+	if d.URL != "" {
+		fmt.Fprintf(&b, " [%s]", d.URL)
+	}
+	return b.String()
+}
+
+// Diagnostics are the messages reported by the page while rendering
+type Diagnostics []Diagnostic
+
+// Errors returns the diagnostics indicating a broken document
+func (ds Diagnostics) Errors() Diagnostics {
+	var errs Diagnostics
+	for _, d := range ds {
+		if d.IsError() {
+			errs = append(errs, d)
+		}
+	}
+	return errs
+}
+
+// Err returns an ErrPageErrors error listing the errors, or nil if there are
+// none
+func (ds Diagnostics) Err() error {
+	errs := ds.Errors()
+	if len(errs) == 0 {
+		return nil
+	}
+	messages := make([]string, len(errs))
+	for i, d := range errs {
+		messages[i] = d.String()
+	}
+	return fmt.Errorf("%w: %s", ErrPageErrors, strings.Join(messages, "; "))
+}
+
+// collector records the diagnostics of a page
+type collector struct {
+	mu          sync.Mutex
+	diagnostics Diagnostics
+	requests    map[proto.NetworkRequestID]string
+}
+
+// collect starts recording the diagnostics of the page. The returned function
+// stops recording and returns the diagnostics.
+func collect(page *rod.Page) func() Diagnostics {
+	c := &collector{requests: make(map[proto.NetworkRequestID]string)}
+	p, cancel := page.WithCancel()
+
+	wait := p.EachEvent(func(e *proto.RuntimeConsoleAPICalled) {
+		args := make([]string, len(e.Args))
+		for i, arg := range e.Args {
+			args[i] = remoteObjectString(arg)
+		}
+		c.add(Diagnostic{Kind: DiagnosticConsole, Level: string(e.Type), Message: strings.Join(args, " ")})
+	}, func(e *proto.RuntimeExceptionThrown) {
+		message := e.ExceptionDetails.Text
+		if e.ExceptionDetails.Exception != nil && e.ExceptionDetails.Exception.Description != "" {
+			message = e.ExceptionDetails.Exception.Description
+		}
+		c.add(Diagnostic{Kind: DiagnosticException, Message: message, URL: e.ExceptionDetails.URL})
+	}, func(e *proto.NetworkRequestWillBeSent) {
+		c.mu.Lock()
+		c.requests[e.RequestID] = e.Request.URL
+		c.mu.Unlock()
+	}, func(e *proto.NetworkResponseReceived) {
+		if e.Response.Status >= 400 {
+			c.add(Diagnostic{Kind: DiagnosticRequest, Message: fmt.Sprintf("HTTP %d", e.Response.Status), URL: e.Response.URL})
+		}
+	}, func(e *proto.NetworkLoadingFailed) {
+		if e.Canceled {
+			return
+		}
+		c.mu.Lock()
+		url := c.requests[e.RequestID]
+		c.mu.Unlock()
+		c.add(Diagnostic{Kind: DiagnosticRequest, Message: e.ErrorText, URL: url})
+	})
+	done := make(chan struct{})
+	go func() {
+		wait()
+		close(done)
+	}()
+
+	return func() Diagnostics {
+		cancel()
+		<-done
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.diagnostics
+	}
+}
+
+func (c *collector) add(d Diagnostic) {
+	c.mu.Lock()
+	c.diagnostics = append(c.diagnostics, d)
+	c.mu.Unlock()
+}
+
+// remoteObjectString formats a console argument
+func remoteObjectString(o *proto.RuntimeRemoteObject) string {
+	if o.Type == proto.RuntimeRemoteObjectTypeString {
+		return o.Value.Str()
+	}
+	if o.Description != "" {
+		return o.Description
+	}
+	return o.Value.JSON("", "")
+}
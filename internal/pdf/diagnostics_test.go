@@ -0,0 +1,34 @@
+package pdf_test
+
+import (
+	"testing"
+
+	"github.com/sehrgutesoftware/httpdf/internal/pdf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiagnostics_Err(t *testing.T) {
+	t.Run("it_returns_nil_without_errors", func(t *testing.T) {
+		diagnostics := pdf.Diagnostics{
+			{Kind: pdf.DiagnosticConsole, Level: "error", Message: "something odd"},
+		}
+
+		assert.NoError(t, diagnostics.Err())
+	})
+
+	t.Run("it_lists_exceptions_and_failed_requests", func(t *testing.T) {
+		diagnostics := pdf.Diagnostics{
+			{Kind: pdf.DiagnosticConsole, Level: "log", Message: "rendering chart"},
+			{Kind: pdf.DiagnosticException, Message: "TypeError: x is undefined", URL: "http://localhost/assets/chart.js"},
+			{Kind: pdf.DiagnosticRequest, Message: "HTTP 404", URL: "http://localhost/assets/logo.png"},
+		}
+
+		err := diagnostics.Err()
+
+		assert.ErrorIs(t, err, pdf.ErrPageErrors)
+		assert.Equal(t, diagnostics[1:], diagnostics.Errors())
+		assert.Contains(t, err.Error(), "exception: TypeError: x is undefined [http://localhost/assets/chart.js]")
+		assert.Contains(t, err.Error(), "request: HTTP 404 [http://localhost/assets/logo.png]")
+		assert.NotContains(t, err.Error(), "rendering chart")
+	})
+}
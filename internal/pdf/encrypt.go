@@ -0,0 +1,81 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+var (
+	// ErrUnknownPermission is returned for unsupported permission names
+	ErrUnknownPermission = errors.New("unknown permission")
+)
+
+// Permission names an action readers of an encrypted PDF are allowed to
+// perform without knowing the owner password
+type Permission string
+
+const (
+	PermissionPrint     Permission = "print"
+	PermissionCopy      Permission = "copy"
+	PermissionModify    Permission = "modify"
+	PermissionAnnotate  Permission = "annotate"
+	PermissionFillForms Permission = "fillForms"
+	PermissionAssemble  Permission = "assemble"
+)
+
+var permissionFlags = map[Permission]model.PermissionFlags{
+	PermissionPrint:     model.PermissionPrintRev2 | model.PermissionPrintRev3,
+	PermissionCopy:      model.PermissionExtract | model.PermissionExtractRev3,
+	PermissionModify:    model.PermissionModify,
+	PermissionAnnotate:  model.PermissionModAnnFillForm,
+	PermissionFillForms: model.PermissionFillRev3,
+	PermissionAssemble:  model.PermissionAssembleRev3,
+}
+
+// EncryptOpts contains options for encrypting a PDF
+type EncryptOpts struct {
+	// UserPassword is required to open the document. If empty, the document
+	// can be opened by anyone, but the permissions still apply.
+	UserPassword string
+	// OwnerPassword grants full access to the document. If empty, a random
+	// password is used, so the permissions can't be lifted.
+	OwnerPassword string
+	// Permissions lists the actions allowed without the owner password
+	Permissions []Permission
+}
+
+// Encrypt encrypts the PDF document using AES-256 and writes the result to out
+func Encrypt(document []byte, out io.Writer, opts EncryptOpts) error {
+	flags := model.PermissionsNone
+	for _, p := range opts.Permissions {
+		flag, ok := permissionFlags[p]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownPermission, p)
+		}
+		flags |= flag
+	}
+
+	ownerPassword := opts.OwnerPassword
+	if ownerPassword == "" {
+		random := make([]byte, 16)
+		if _, err := rand.Read(random); err != nil {
+			return fmt.Errorf("generate owner password: %w", err)
+		}
+		ownerPassword = hex.EncodeToString(random)
+	}
+
+	conf := model.NewAESConfiguration(opts.UserPassword, ownerPassword, 256)
+	conf.Permissions = flags
+	if err := api.Encrypt(bytes.NewReader(document), out, conf); err != nil {
+		return fmt.Errorf("encrypt PDF: %w", err)
+	}
+
+	return nil
+}
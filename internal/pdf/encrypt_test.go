@@ -0,0 +1,72 @@
+package pdf_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/sehrgutesoftware/httpdf/internal/pdf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncrypt(t *testing.T) {
+	t.Run("it_encrypts_a_document_with_a_user_password", func(t *testing.T) {
+		var out bytes.Buffer
+		err := pdf.Encrypt(testDocument(1), &out, pdf.EncryptOpts{
+			UserPassword:  "user",
+			OwnerPassword: "owner",
+		})
+
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), "/Encrypt")
+
+		_, err = api.ReadContext(bytes.NewReader(out.Bytes()), model.NewDefaultConfiguration())
+		assert.Error(t, err, "document must not open without password")
+
+		conf := model.NewDefaultConfiguration()
+		conf.UserPW = "user"
+		ctx, err := api.ReadContext(bytes.NewReader(out.Bytes()), conf)
+		require.NoError(t, err)
+		assert.Equal(t, 5, ctx.E.V, "expected AES-256 security handler")
+	})
+
+	t.Run("it_applies_the_given_permissions", func(t *testing.T) {
+		var out bytes.Buffer
+		err := pdf.Encrypt(testDocument(1), &out, pdf.EncryptOpts{
+			OwnerPassword: "owner",
+			Permissions:   []pdf.Permission{pdf.PermissionPrint, pdf.PermissionCopy},
+		})
+		require.NoError(t, err)
+
+		conf := model.NewDefaultConfiguration()
+		conf.OwnerPW = "owner"
+		perms, err := api.GetPermissions(bytes.NewReader(out.Bytes()), conf)
+
+		require.NoError(t, err)
+		require.NotNil(t, perms)
+		flags := model.PermissionFlags(*perms)
+		assert.NotZero(t, flags&model.PermissionPrintRev3)
+		assert.NotZero(t, flags&model.PermissionExtract)
+		assert.Zero(t, flags&model.PermissionModify)
+		assert.Zero(t, flags&model.PermissionAssembleRev3)
+	})
+
+	t.Run("it_returns_an_error_for_unknown_permissions", func(t *testing.T) {
+		err := pdf.Encrypt(testDocument(1), &bytes.Buffer{}, pdf.EncryptOpts{
+			Permissions: []pdf.Permission{"teleport"},
+		})
+
+		assert.ErrorIs(t, err, pdf.ErrUnknownPermission)
+	})
+
+	t.Run("it_cannot_sign_an_encrypted_document", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, pdf.Encrypt(testDocument(1), &out, pdf.EncryptOpts{}))
+
+		err := pdf.Sign(out.Bytes(), &bytes.Buffer{}, selfSignedCredentials(t), pdf.SignOpts{})
+
+		assert.Error(t, err)
+	})
+}
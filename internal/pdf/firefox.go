@@ -0,0 +1,463 @@
+package pdf
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	// firefoxStartTimeout is the maximum time to wait for Firefox to accept
+	// WebDriver BiDi connections
+	firefoxStartTimeout = 30 * time.Second
+	// pollInterval is the interval of checking selector and expression
+	// wait conditions
+	pollInterval = 100 * time.Millisecond
+	// stableTime is the time without pending requests after which the page
+	// is printed, if no wait conditions are given
+	stableTime = 50 * time.Millisecond
+)
+
+// firefoxPrefs are written to the user.js of the temporary profile
+const firefoxPrefs = `user_pref("browser.shell.checkDefaultBrowser", false);
+user_pref("datareporting.policy.dataSubmissionEnabled", false);
+user_pref("toolkit.telemetry.reportingpolicy.firstRun", false);
+user_pref("app.update.disabledForTesting", true);
+user_pref("browser.startup.homepage_override.mstone", "ignore");
+`
+
+// firefoxListening matches the line Firefox logs once the WebDriver BiDi
+// endpoint is ready
+var firefoxListening = regexp.MustCompile(`WebDriver BiDi listening on (ws://\S+)`)
+
+// firefoxRenderer is a Renderer implementation that drives Firefox using
+// WebDriver BiDi. Tagged PDFs and document outlines are not supported by
+// Firefox and are ignored.
+type firefoxRenderer struct {
+	firefox string
+}
+
+// NewFirefoxRenderer creates a Renderer that launches the Firefox binary at
+// the given path for each render
+func NewFirefoxRenderer(firefox string) Renderer {
+	return &firefoxRenderer{
+		firefox: firefox,
+	}
+}
+
+// Render renders a PDF from HTML content
+func (r *firefoxRenderer) Render(ctx context.Context, url string, pdf io.Writer, opts RenderOpts) (Diagnostics, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	// Launch Firefox with a fresh profile
+	profile, err := os.MkdirTemp("", "httpdf-firefox-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create profile: %w", err)
+	}
+	defer os.RemoveAll(profile)
+	if err := os.WriteFile(filepath.Join(profile, "user.js"), []byte(firefoxPrefs), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to create profile: %w", err)
+	}
+
+	browserCtx, cancel := context.WithCancel(ctx)
+	cmd := exec.CommandContext(browserCtx, r.firefox,
+		"--headless",
+		"--no-remote",
+		"--profile", profile,
+		"--remote-debugging-port", "0",
+	)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to launch browser: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to launch browser: %w", err)
+	}
+	defer func() {
+		cancel()
+		_ = cmd.Wait()
+	}()
+
+	endpoint, err := waitForBiDi(ctx, stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to launch browser: %w", err)
+	}
+
+	// Connect to the browser
+	page := &firefoxPage{
+		origin:   url,
+		network:  opts.Network,
+		inflight: make(map[string]bool),
+	}
+	page.conn, err = dialBiDi(ctx, endpoint+"/session", page.event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to browser: %w", err)
+	}
+	defer page.conn.close()
+	if err := page.conn.call(ctx, "session.new", map[string]any{"capabilities": map[string]any{}}, nil); err != nil {
+		return nil, fmt.Errorf("failed to connect to browser: %w", err)
+	}
+
+	return page.render(ctx, pdf, opts)
+}
+
+// waitForBiDi reads the output of Firefox until it logs the WebDriver BiDi
+// endpoint, and forwards the remaining output to stderr
+func waitForBiDi(ctx context.Context, output io.Reader) (string, error) {
+	found := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(output)
+		for scanner.Scan() {
+			if m := firefoxListening.FindStringSubmatch(scanner.Text()); m != nil {
+				found <- m[1]
+				break
+			}
+		}
+		close(found)
+		_, _ = io.Copy(os.Stderr, output)
+	}()
+
+	select {
+	case endpoint, ok := <-found:
+		if !ok {
+			return "", fmt.Errorf("browser exited before accepting connections")
+		}
+		return endpoint, nil
+	case <-time.After(firefoxStartTimeout):
+		return "", fmt.Errorf("browser didn't accept connections within %s", firefoxStartTimeout)
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// firefoxPage is a browsing context rendered by Firefox
+type firefoxPage struct {
+	conn    *bidiConn
+	context string
+	origin  string
+	network NetworkPolicy
+
+	mu           sync.Mutex
+	diagnostics  Diagnostics
+	inflight     map[string]bool
+	lastActivity time.Time
+}
+
+// render loads the page, enforcing the network policy, and prints it as PDF
+func (p *firefoxPage) render(ctx context.Context, pdf io.Writer, opts RenderOpts) (Diagnostics, error) {
+	var created struct {
+		Context string `json:"context"`
+	}
+	if err := p.conn.call(ctx, "browsingContext.create", map[string]any{"type": "tab"}, &created); err != nil {
+		return nil, fmt.Errorf("failed to create new page: %w", err)
+	}
+	p.context = created.Context
+
+	contexts := []string{p.context}
+	if err := p.conn.call(ctx, "session.subscribe", map[string]any{
+		"events": []string{
+			"log.entryAdded",
+			"network.beforeRequestSent",
+			"network.responseCompleted",
+			"network.fetchError",
+		},
+		"contexts": contexts,
+	}, nil); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to events: %w", err)
+	}
+	if err := p.conn.call(ctx, "network.addIntercept", map[string]any{
+		"phases":   []string{"beforeRequestSent"},
+		"contexts": contexts,
+	}, nil); err != nil {
+		return nil, fmt.Errorf("failed to intercept requests: %w", err)
+	}
+
+	// Firefox has no print media emulation in WebDriver BiDi, so screenshots
+	// show the screen media styles
+	if opts.Format == FormatPNG {
+		if err := p.conn.call(ctx, "browsingContext.setViewport", map[string]any{
+			"context":  p.context,
+			"viewport": map[string]int{"width": pixels(opts.Width), "height": pixels(opts.Height)},
+		}, nil); err != nil {
+			return nil, fmt.Errorf("failed to set up viewport: %w", err)
+		}
+	}
+
+	err := p.load(ctx, opts.Wait)
+	p.mu.Lock()
+	diagnostics := p.diagnostics
+	p.mu.Unlock()
+	if err != nil {
+		return diagnostics, err
+	}
+	if opts.Strict {
+		if err := diagnostics.Err(); err != nil {
+			return diagnostics, err
+		}
+	}
+
+	if opts.Format == FormatPNG {
+		var captured struct {
+			Data string `json:"data"`
+		}
+		err = p.conn.call(ctx, "browsingContext.captureScreenshot", map[string]any{
+			"context": p.context,
+			"origin":  "document",
+			"format":  map[string]string{"type": "image/png"},
+		}, &captured)
+		if err != nil {
+			return diagnostics, fmt.Errorf("failed to take screenshot: %w", err)
+		}
+		screenshot, err := base64.StdEncoding.DecodeString(captured.Data)
+		if err != nil {
+			return diagnostics, fmt.Errorf("failed to take screenshot: %w", err)
+		}
+		if _, err := pdf.Write(screenshot); err != nil {
+			return diagnostics, fmt.Errorf("failed to write screenshot to output stream: %w", err)
+		}
+		return diagnostics, nil
+	}
+
+	// Save the page as a PDF; Firefox expects sizes in cm
+	var printed struct {
+		Data string `json:"data"`
+	}
+	err = p.conn.call(ctx, "browsingContext.print", map[string]any{
+		"context":     p.context,
+		"background":  true,
+		"margin":      map[string]float64{"top": 0, "bottom": 0, "left": 0, "right": 0},
+		"page":        map[string]float64{"width": opts.Width / 10, "height": opts.Height / 10},
+		"shrinkToFit": false,
+	}, &printed)
+	if err != nil {
+		return diagnostics, fmt.Errorf("failed generate PDF from HTML: %w", err)
+	}
+	document, err := base64.StdEncoding.DecodeString(printed.Data)
+	if err != nil {
+		return diagnostics, fmt.Errorf("failed generate PDF from HTML: %w", err)
+	}
+
+	// Write the PDF to the output stream
+	if _, err := pdf.Write(document); err != nil {
+		return diagnostics, fmt.Errorf("failed to write PDF to output stream: %w", err)
+	}
+
+	return diagnostics, nil
+}
+
+// load navigates to the page and waits for the conditions
+func (p *firefoxPage) load(ctx context.Context, conditions []Wait) error {
+	if err := p.conn.call(ctx, "browsingContext.navigate", map[string]any{
+		"context": p.context,
+		"url":     p.origin,
+		"wait":    "complete",
+	}, nil); err != nil {
+		return fmt.Errorf("failed to load page: %w", err)
+	}
+
+	if len(conditions) == 0 {
+		waitCtx, cancel := context.WithTimeout(ctx, defaultWaitTimeout)
+		defer cancel()
+		if err := p.waitNetworkIdle(waitCtx, stableTime); err != nil {
+			return fmt.Errorf("failed to wait for page load: %w", err)
+		}
+		return nil
+	}
+
+	for _, w := range conditions {
+		var err error
+		if w.Delay > 0 {
+			select {
+			case <-time.After(w.Delay):
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		} else {
+			waitCtx, cancel := context.WithTimeout(ctx, w.timeout())
+			switch {
+			case w.Selector != "":
+				selector, _ := json.Marshal(w.Selector)
+				err = p.poll(waitCtx, fmt.Sprintf("document.querySelector(%s) !== null", selector))
+			case w.Expression != "":
+				err = p.poll(waitCtx, "!!("+w.Expression+")")
+			case w.Fonts:
+				_, err = p.evaluate(waitCtx, "document.fonts.ready.then(() => true)", true)
+			case w.NetworkIdle:
+				err = p.waitNetworkIdle(waitCtx, networkIdleTime)
+			}
+			cancel()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to wait for %s: %w", w, err)
+		}
+	}
+
+	return nil
+}
+
+// evaluate evaluates the JS expression in the page and reports whether the
+// result is true
+func (p *firefoxPage) evaluate(ctx context.Context, expression string, awaitPromise bool) (bool, error) {
+	var res struct {
+		Type   string `json:"type"`
+		Result struct {
+			Type  string          `json:"type"`
+			Value json.RawMessage `json:"value"`
+		} `json:"result"`
+		ExceptionDetails struct {
+			Text string `json:"text"`
+		} `json:"exceptionDetails"`
+	}
+	err := p.conn.call(ctx, "script.evaluate", map[string]any{
+		"expression":   expression,
+		"target":       map[string]string{"context": p.context},
+		"awaitPromise": awaitPromise,
+	}, &res)
+	if err != nil {
+		return false, err
+	}
+	if res.Type == "exception" {
+		return false, fmt.Errorf("evaluate: %s", res.ExceptionDetails.Text)
+	}
+
+	return res.Result.Type == "boolean" && string(res.Result.Value) == "true", nil
+}
+
+// poll evaluates the JS expression until it is true
+func (p *firefoxPage) poll(ctx context.Context, expression string) error {
+	for {
+		ok, err := p.evaluate(ctx, expression, false)
+		if err != nil || ok {
+			return err
+		}
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// waitNetworkIdle waits until there have been no pending requests for d
+func (p *firefoxPage) waitNetworkIdle(ctx context.Context, d time.Duration) error {
+	for {
+		p.mu.Lock()
+		idle := len(p.inflight) == 0 && time.Since(p.lastActivity) >= d
+		p.mu.Unlock()
+		if idle {
+			return nil
+		}
+		select {
+		case <-time.After(stableTime):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// event handles the events of the browsing context
+func (p *firefoxPage) event(method string, params json.RawMessage) {
+	var e struct {
+		IsBlocked bool `json:"isBlocked"`
+		Request   struct {
+			Request string `json:"request"`
+			URL     string `json:"url"`
+		} `json:"request"`
+		Response struct {
+			Status int `json:"status"`
+		} `json:"response"`
+		ErrorText string `json:"errorText"`
+		// log.entryAdded
+		Type       string `json:"type"`
+		Level      string `json:"level"`
+		Text       string `json:"text"`
+		StackTrace struct {
+			CallFrames []struct {
+				URL string `json:"url"`
+			} `json:"callFrames"`
+		} `json:"stackTrace"`
+	}
+	if err := json.Unmarshal(params, &e); err != nil {
+		return
+	}
+
+	switch method {
+	case "network.beforeRequestSent":
+		p.requestStarted(e.Request.Request)
+		if !e.IsBlocked {
+			return
+		}
+		// Commands can't be awaited while handling events
+		command := "network.continueRequest"
+		if !p.network.Allows(p.origin, e.Request.URL) {
+			log.Printf("Blocked request to %s", e.Request.URL)
+			command = "network.failRequest"
+		}
+		go p.resume(command, e.Request.Request, e.Request.URL)
+	case "network.responseCompleted":
+		if e.Response.Status >= 400 {
+			p.add(Diagnostic{Kind: DiagnosticRequest, Message: fmt.Sprintf("HTTP %d", e.Response.Status), URL: e.Request.URL})
+		}
+		p.requestDone(e.Request.Request)
+	case "network.fetchError":
+		p.add(Diagnostic{Kind: DiagnosticRequest, Message: e.ErrorText, URL: e.Request.URL})
+		p.requestDone(e.Request.Request)
+	case "log.entryAdded":
+		switch e.Type {
+		case "javascript":
+			d := Diagnostic{Kind: DiagnosticException, Message: e.Text}
+			if len(e.StackTrace.CallFrames) > 0 {
+				d.URL = e.StackTrace.CallFrames[0].URL
+			}
+			p.add(d)
+		default:
+			p.add(Diagnostic{Kind: DiagnosticConsole, Level: e.Level, Message: e.Text})
+		}
+	}
+}
+
+// resume continues or fails the intercepted request. If that fails, the
+// request stays blocked, so the failure is recorded as diagnostic.
+func (p *firefoxPage) resume(command, request, url string) {
+	err := p.conn.call(context.Background(), command, map[string]string{"request": request}, nil)
+	// Requests pending when the page is closed don't matter anymore
+	if err != nil && !errors.Is(err, errConnectionClosed) {
+		log.Printf("Failed to resume request to %s: %v", url, err)
+		p.add(Diagnostic{Kind: DiagnosticRequest, Message: err.Error(), URL: url})
+	}
+}
+
+func (p *firefoxPage) add(d Diagnostic) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.diagnostics = append(p.diagnostics, d)
+}
+
+func (p *firefoxPage) requestStarted(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inflight[id] = true
+	p.lastActivity = time.Now()
+}
+
+func (p *firefoxPage) requestDone(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.inflight, id)
+	p.lastActivity = time.Now()
+}
@@ -0,0 +1,263 @@
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/net/html"
+)
+
+var (
+	// ErrUnsupported is returned by the native renderer for HTML and CSS
+	// features it doesn't support
+	ErrUnsupported = errors.New("unsupported by the native renderer")
+
+	// errBlocked is returned by CheckRedirect for redirects to URLs outside
+	// the network policy
+	errBlocked = errors.New("blocked by network policy")
+)
+
+// nativeRenderer is a Renderer implementation that renders a restricted subset
+// of HTML and CSS directly to PDF, without a browser. JavaScript, web fonts and
+// most of CSS layout are not supported; see the README for the subset.
+type nativeRenderer struct {
+	client *http.Client
+}
+
+// NewNativeRenderer creates a Renderer that renders simple templates without
+// a browser
+func NewNativeRenderer() Renderer {
+	return &nativeRenderer{
+		client: &http.Client{},
+	}
+}
+
+// Render renders a PDF from HTML content
+func (r *nativeRenderer) Render(ctx context.Context, pageURL string, pdf io.Writer, opts RenderOpts) (Diagnostics, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if len(opts.Wait) > 0 {
+		return nil, fmt.Errorf("%w: wait conditions", ErrUnsupported)
+	}
+	if opts.Format != FormatPDF {
+		return nil, fmt.Errorf("%w: formats other than PDF", ErrUnsupported)
+	}
+
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load page: %w", err)
+	}
+	d := &nativeDocument{
+		ctx:     ctx,
+		base:    base,
+		network: opts.Network,
+	}
+	// Redirects are checked against the network policy as well, so the
+	// client is copied to set the policy of this render
+	client := *r.client
+	client.CheckRedirect = d.checkRedirect
+	d.client = &client
+
+	page, err := d.fetch(pageURL)
+	if err != nil {
+		return d.diagnostics, fmt.Errorf("failed to load page: %w", err)
+	}
+	root, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return d.diagnostics, fmt.Errorf("failed to parse page: %w", err)
+	}
+
+	if err := d.render(root, pdf, opts); err != nil {
+		return d.diagnostics, err
+	}
+	if opts.Strict {
+		if err := d.diagnostics.Err(); err != nil {
+			return d.diagnostics, err
+		}
+	}
+
+	return d.diagnostics, nil
+}
+
+// nativeDocument is a page rendered by the native renderer
+type nativeDocument struct {
+	ctx         context.Context
+	client      *http.Client
+	base        *url.URL
+	network     NetworkPolicy
+	diagnostics Diagnostics
+}
+
+// fetch loads a resource referenced by the page, enforcing the network policy
+func (d *nativeDocument) fetch(ref string) ([]byte, error) {
+	u, err := d.base.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+	if !d.network.Allows(d.base.String(), u.String()) {
+		log.Printf("Blocked request to %s", u)
+		return nil, d.failed(u.String(), errBlocked.Error())
+	}
+
+	if u.Scheme == "data" {
+		return decodeDataURL(u.String())
+	}
+
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := d.client.Do(req)
+	var redirect *url.Error
+	if errors.Is(err, errBlocked) && errors.As(err, &redirect) {
+		log.Printf("Blocked request to %s", redirect.URL)
+		return nil, d.failed(redirect.URL, errBlocked.Error())
+	} else if err != nil {
+		return nil, d.failed(u.String(), err.Error())
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 400 {
+		return nil, d.failed(u.String(), fmt.Sprintf("HTTP %d", res.StatusCode))
+	}
+
+	return io.ReadAll(res.Body)
+}
+
+// checkRedirect stops redirects to URLs outside the network policy
+func (d *nativeDocument) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if !d.network.Allows(d.base.String(), req.URL.String()) {
+		return errBlocked
+	}
+	return nil
+}
+
+// failed records a failed request and returns it as error
+func (d *nativeDocument) failed(u, message string) error {
+	d.diagnostics = append(d.diagnostics, Diagnostic{Kind: DiagnosticRequest, Message: message, URL: u})
+	return fmt.Errorf("%s: %s", u, message)
+}
+
+// decodeDataURL returns the content of a data: URL
+func decodeDataURL(u string) ([]byte, error) {
+	meta, data, ok := strings.Cut(strings.TrimPrefix(u, "data:"), ",")
+	if !ok {
+		return nil, fmt.Errorf("invalid data URL")
+	}
+	if strings.HasSuffix(meta, ";base64") {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	decoded, err := url.PathUnescape(data)
+	return []byte(decoded), err
+}
+
+// render lays out the document and writes the PDF
+func (d *nativeDocument) render(root *html.Node, out io.Writer, opts RenderOpts) error {
+	f := fpdf.NewCustom(&fpdf.InitType{
+		UnitStr: "mm",
+		Size:    fpdf.SizeType{Wd: opts.Width, Ht: opts.Height},
+	})
+	f.SetAutoPageBreak(false, 0)
+	f.SetMargins(0, 0, 0)
+	f.SetCreator("httpdf", true)
+
+	// Collect the stylesheets first, as they apply to the whole document
+	ss := &stylesheet{}
+	var title string
+	var collect func(n *html.Node) error
+	collect = func(n *html.Node) error {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "style":
+				if n.FirstChild != nil {
+					if err := ss.parse(n.FirstChild.Data); err != nil {
+						return err
+					}
+				}
+			case "link":
+				if strings.EqualFold(attr(n, "rel"), "stylesheet") {
+					css, err := d.fetch(attr(n, "href"))
+					if err != nil {
+						return nil
+					}
+					if err := ss.parse(string(css)); err != nil {
+						return err
+					}
+				}
+			case "title":
+				if n.FirstChild != nil {
+					title = n.FirstChild.Data
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if err := collect(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := collect(root); err != nil {
+		return err
+	}
+	ss.sort()
+	if title != "" {
+		f.SetTitle(title, true)
+	}
+
+	l := &layout{
+		doc:    d,
+		f:      f,
+		ss:     ss,
+		width:  opts.Width,
+		height: opts.Height,
+		pages:  1,
+	}
+	if len(ss.pageMargin) > 0 {
+		page := rootStyle()
+		if err := page.apply(ss.pageMargin, page); err != nil {
+			return fmt.Errorf("@page: %w", err)
+		}
+		l.pageMargin = page.margin
+	}
+	l.y = l.pageMargin[0]
+
+	htmlNode := findElement(root, "html")
+	if htmlNode == nil {
+		return fmt.Errorf("failed to parse page: missing html element")
+	}
+	if err := l.block(htmlNode, rootStyle(), l.pageMargin[3], opts.Width-l.pageMargin[1]-l.pageMargin[3]); err != nil {
+		return err
+	}
+
+	l.paint()
+	if err := f.Output(out); err != nil {
+		return fmt.Errorf("failed to write PDF to output stream: %w", err)
+	}
+	return nil
+}
+
+// findElement returns the first element with the given tag name
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
@@ -0,0 +1,839 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/net/html"
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	// Vertical metrics of the built-in fonts, relative to the font size
+	fontAscent  = 0.718
+	fontDescent = 0.207
+	// markerGap is the space between a list marker and the list item
+	markerGap = 2.0
+	// epsilon absorbs rounding errors when comparing positions
+	epsilon = 0.01
+)
+
+// opKind is the kind of a drawing operation
+type opKind int
+
+const (
+	opText opKind = iota
+	opRect
+	opImage
+)
+
+// op is a drawing operation on a page. Operations are collected during
+// layout, so backgrounds can be inserted below content whose height is only
+// known afterwards.
+type op struct {
+	page       int
+	kind       opKind
+	x, y, w, h float64
+	color      rgb
+	// text
+	text      string
+	font      string
+	fontStyle string
+	fontSize  float64
+	// image
+	image string
+}
+
+// image is an image registered with the PDF
+type image struct {
+	name string
+	// width and height in mm at 96 dpi
+	width, height float64
+	err           error
+}
+
+// layout places the boxes of the document on pages
+type layout struct {
+	doc        *nativeDocument
+	f          *fpdf.Fpdf
+	ss         *stylesheet
+	width      float64
+	height     float64
+	pageMargin [4]float64
+
+	ops    []op
+	pages  int
+	page   int
+	y      float64
+	styles map[*html.Node]*style
+	images map[string]*image
+
+	// prevMargin is the bottom margin of the previous block, which collapses
+	// with the top margin of the next one
+	prevMargin float64
+	// marker is the pending marker of a list item, drawn with its first line
+	marker      string
+	markerStyle *style
+	// noBreak disables page breaks, e.g. within table rows
+	noBreak bool
+}
+
+func (l *layout) top() float64 {
+	return l.pageMargin[0]
+}
+
+func (l *layout) bottom() float64 {
+	return l.height - l.pageMargin[2]
+}
+
+// ensure starts a new page if a box of height h doesn't fit on the current one
+func (l *layout) ensure(h float64) {
+	if !l.noBreak && l.y+h > l.bottom()+epsilon && l.y > l.top()+epsilon {
+		l.newPage()
+	}
+}
+
+// advance moves down by h, continuing on the next pages if the space on the
+// current one isn't sufficient
+func (l *layout) advance(h float64) {
+	for !l.noBreak && l.y+h > l.bottom()+epsilon {
+		h -= l.bottom() - l.y
+		l.newPage()
+	}
+	l.y += h
+}
+
+func (l *layout) newPage() {
+	l.page++
+	l.pages = max(l.pages, l.page+1)
+	l.y = l.top()
+	l.prevMargin = 0
+}
+
+// style returns the computed style of the element
+func (l *layout) style(n *html.Node, parent *style) (*style, error) {
+	if s, ok := l.styles[n]; ok {
+		return s, nil
+	}
+	s, err := l.ss.computeStyle(n, parent)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path(n), err)
+	}
+	if l.styles == nil {
+		l.styles = make(map[*html.Node]*style)
+	}
+	l.styles[n] = s
+	return s, nil
+}
+
+// block lays out a block box in the containing block [x, x+w]
+func (l *layout) block(n *html.Node, s *style, x, w float64) error {
+	bx, bw := l.beginBox(s, x, w)
+	startPage, startY, opIndex := l.page, l.y, len(l.ops)
+
+	l.y += s.border[0].width + s.padding[0]
+	l.prevMargin = 0
+	contentPage, contentTop := l.page, l.y
+	cx := bx + s.border[3].width + s.padding[3]
+	cw := bw - s.border[3].width - s.padding[3] - s.padding[1] - s.border[1].width
+	if err := l.contents(n, s, cx, cw); err != nil {
+		return err
+	}
+	if s.height.set && !s.height.percent && l.page == contentPage && l.y-contentTop < s.height.value {
+		l.advance(contentTop + s.height.value - l.y)
+	}
+	l.y += s.padding[2] + s.border[2].width
+
+	l.decorate(opIndex, s, bx, bw, startPage, startY)
+	l.endBox(s)
+	return nil
+}
+
+// beginBox applies the page break and top margin of a box, and returns the
+// position and width of its border box
+func (l *layout) beginBox(s *style, x, w float64) (float64, float64) {
+	if s.pageBreakBefore && !l.noBreak && l.y > l.top()+epsilon {
+		l.newPage()
+	}
+	l.y += max(s.margin[0], l.prevMargin) - l.prevMargin
+
+	bx := x + s.margin[3]
+	bw := w - s.margin[3] - s.margin[1]
+	if s.width.set {
+		bw = s.width.resolve(w) + s.padding[3] + s.padding[1] + s.border[3].width + s.border[1].width
+	}
+	return bx, bw
+}
+
+// endBox applies the bottom margin and page break of a box
+func (l *layout) endBox(s *style) {
+	l.y += s.margin[2]
+	l.prevMargin = s.margin[2]
+	if s.pageBreakAfter && !l.noBreak {
+		l.newPage()
+	}
+}
+
+// contents lays out the children of an element in the content box
+// [x, x+w]. Consecutive inline children form paragraphs of lines.
+func (l *layout) contents(n *html.Node, s *style, x, w float64) error {
+	var inline []*html.Node
+	flush := func() error {
+		if len(inline) == 0 {
+			return nil
+		}
+		err := l.inline(inline, s, x, w)
+		inline = nil
+		return err
+	}
+
+	items := 0
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case html.TextNode:
+			inline = append(inline, c)
+			continue
+		case html.ElementNode:
+		default:
+			continue
+		}
+
+		switch c.Data {
+		case "head", "style", "link", "meta", "title":
+			continue
+		case "script", "noscript", "template":
+			return fmt.Errorf("%s: %w: element <%s>", path(c), ErrUnsupported, c.Data)
+		}
+
+		cs, err := l.style(c, s)
+		if err != nil {
+			return err
+		}
+
+		var lerr error
+		switch cs.display {
+		case "none":
+			continue
+		case "inline", "break":
+			inline = append(inline, c)
+			continue
+		case "block":
+			if lerr = flush(); lerr == nil {
+				lerr = l.block(c, cs, x, w)
+			}
+		case "list-item":
+			items++
+			if lerr = flush(); lerr == nil {
+				l.marker, l.markerStyle = "•", cs
+				if n.Data == "ol" {
+					l.marker = strconv.Itoa(items) + "."
+				}
+				lerr = l.block(c, cs, x, w)
+				l.marker = ""
+			}
+		case "image":
+			if lerr = flush(); lerr == nil {
+				lerr = l.image(c, cs, s, x, w)
+			}
+		case "table":
+			if lerr = flush(); lerr == nil {
+				lerr = l.table(c, cs, x, w)
+			}
+		default:
+			lerr = fmt.Errorf("%s: %w: <%s> outside of a table", path(c), ErrUnsupported, c.Data)
+		}
+		if lerr != nil {
+			return lerr
+		}
+	}
+
+	return flush()
+}
+
+// word is a piece of text without break opportunities
+type word struct {
+	text  string
+	style *style
+	width float64
+	// space indicates whitespace before the word, i.e. a break opportunity
+	space bool
+	// lineBreak is a forced line break (<br>) instead of a word
+	lineBreak bool
+}
+
+// inline lays out inline content as lines in the content box [x, x+w]
+func (l *layout) inline(nodes []*html.Node, s *style, x, w float64) error {
+	var words []word
+	space := false
+	var walk func(n *html.Node, s *style) error
+	walk = func(n *html.Node, s *style) error {
+		switch n.Type {
+		case html.TextNode:
+			var current strings.Builder
+			emit := func() error {
+				if current.Len() == 0 {
+					return nil
+				}
+				text, err := encodeText(current.String())
+				if err != nil {
+					return fmt.Errorf("%s: %w", path(n.Parent), err)
+				}
+				words = append(words, word{text: text, style: s, width: l.textWidth(text, s), space: space})
+				current.Reset()
+				space = false
+				return nil
+			}
+			for _, r := range n.Data {
+				if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' {
+					if err := emit(); err != nil {
+						return err
+					}
+					space = true
+					continue
+				}
+				current.WriteRune(r)
+			}
+			return emit()
+		case html.ElementNode:
+			cs, err := l.style(n, s)
+			if err != nil {
+				return err
+			}
+			switch cs.display {
+			case "none":
+				return nil
+			case "break":
+				words = append(words, word{style: cs, lineBreak: true})
+				space = false
+				return nil
+			case "inline":
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					if err := walk(c, cs); err != nil {
+						return err
+					}
+				}
+				return nil
+			default:
+				return fmt.Errorf("%s: %w: <%s> inside inline content", path(n), ErrUnsupported, n.Data)
+			}
+		}
+		return nil
+	}
+	for _, n := range nodes {
+		if err := walk(n, s); err != nil {
+			return err
+		}
+	}
+
+	// Break the words into lines
+	var line []word
+	lineWidth := 0.0
+	for i := 0; i < len(words); {
+		if words[i].lineBreak {
+			l.line(line, lineWidth, s, words[i].style, x, w)
+			line, lineWidth = nil, 0
+			i++
+			continue
+		}
+
+		// Words without whitespace between them can't be separated
+		j := i + 1
+		for j < len(words) && !words[j].space && !words[j].lineBreak {
+			j++
+		}
+		unit := words[i:j]
+		unitWidth := 0.0
+		for _, wd := range unit {
+			unitWidth += wd.width
+		}
+		spaceWidth := 0.0
+		if len(line) > 0 && unit[0].space {
+			spaceWidth = l.textWidth(" ", unit[0].style)
+		}
+
+		if len(line) > 0 && lineWidth+spaceWidth+unitWidth > w+epsilon {
+			l.line(line, lineWidth, s, nil, x, w)
+			line, lineWidth, spaceWidth = nil, 0, 0
+		}
+		if spaceWidth > 0 {
+			unit[0].text = " " + unit[0].text
+			unit[0].width += spaceWidth
+		}
+		line = append(line, unit...)
+		lineWidth += spaceWidth + unitWidth
+		i = j
+	}
+	if len(line) > 0 {
+		l.line(line, lineWidth, s, nil, x, w)
+	}
+
+	return nil
+}
+
+// line places a line of words. Empty lines, e.g. from consecutive <br>, take
+// the height of the given style.
+func (l *layout) line(words []word, width float64, s, empty *style, x, w float64) {
+	fontSize := 0.0
+	height := 0.0
+	for _, wd := range words {
+		fontSize = max(fontSize, wd.style.fontSizeMM())
+		height = max(height, wd.style.fontSizeMM()*wd.style.lineHeight)
+	}
+	if len(words) == 0 {
+		if empty == nil {
+			empty = s
+		}
+		fontSize = empty.fontSizeMM()
+		height = fontSize * empty.lineHeight
+	}
+
+	l.ensure(height)
+	baseline := l.y + (height-fontSize*(fontAscent+fontDescent))/2 + fontSize*fontAscent
+
+	if l.marker != "" {
+		marker, _ := encodeText(l.marker)
+		mw := l.textWidth(marker, l.markerStyle)
+		l.text(marker, l.markerStyle, x-mw-markerGap, baseline)
+		l.marker = ""
+	}
+
+	cx := x
+	switch s.textAlign {
+	case "center":
+		cx += (w - width) / 2
+	case "right":
+		cx += w - width
+	}
+	for _, wd := range words {
+		l.text(wd.text, wd.style, cx, baseline)
+		cx += wd.width
+	}
+
+	l.y += height
+	l.prevMargin = 0
+}
+
+// text adds a text operation
+func (l *layout) text(text string, s *style, x, baseline float64) {
+	fontStyle := s.fontStyle()
+	if s.underline {
+		fontStyle += "U"
+	}
+	l.ops = append(l.ops, op{
+		page:      l.page,
+		kind:      opText,
+		x:         x,
+		y:         baseline,
+		color:     s.color,
+		text:      text,
+		font:      s.fontFamily,
+		fontStyle: fontStyle,
+		fontSize:  s.fontSize,
+	})
+}
+
+// textWidth measures the encoded text in mm
+func (l *layout) textWidth(text string, s *style) float64 {
+	l.f.SetFont(s.fontFamily, s.fontStyle(), s.fontSize)
+	return l.f.GetStringWidth(text)
+}
+
+// encodeText encodes the text for the built-in fonts
+func encodeText(text string) (string, error) {
+	encoded := make([]byte, 0, len(text))
+	for _, r := range text {
+		b, ok := charmap.Windows1252.EncodeRune(r)
+		if !ok {
+			return "", fmt.Errorf("%w: character %q (only Windows-1252 characters are available)", ErrUnsupported, r)
+		}
+		encoded = append(encoded, b)
+	}
+	return string(encoded), nil
+}
+
+// image lays out an image as a block of its own, aligned according to the
+// parent's text-align
+func (l *layout) image(n *html.Node, s, parent *style, x, w float64) error {
+	img := l.loadImage(attr(n, "src"))
+	if img.err != nil {
+		return fmt.Errorf("%s: %w", path(n), img.err)
+	}
+	if img.name == "" {
+		// The image failed to load, which is reported as diagnostic
+		return nil
+	}
+
+	iw, ih := img.width, img.height
+	switch {
+	case s.width.set && s.height.set && !s.height.percent:
+		iw, ih = s.width.resolve(w), s.height.value
+	case s.width.set:
+		iw = s.width.resolve(w)
+		ih = img.height * iw / img.width
+	case s.height.set && !s.height.percent:
+		ih = s.height.value
+		iw = img.width * ih / img.height
+	}
+	if iw > w {
+		ih *= w / iw
+		iw = w
+	}
+
+	l.y += max(s.margin[0], l.prevMargin) - l.prevMargin
+	l.ensure(ih)
+	ix := x
+	switch parent.textAlign {
+	case "center":
+		ix += (w - iw) / 2
+	case "right":
+		ix += w - iw
+	}
+	l.ops = append(l.ops, op{page: l.page, kind: opImage, x: ix, y: l.y, w: iw, h: ih, image: img.name})
+	l.y += ih
+	l.marker = ""
+	l.endBox(s)
+	return nil
+}
+
+// loadImage fetches and registers the image. Images are loaded once per
+// source.
+func (l *layout) loadImage(src string) *image {
+	if img, ok := l.images[src]; ok {
+		return img
+	}
+	if l.images == nil {
+		l.images = make(map[string]*image)
+	}
+	img := &image{}
+	l.images[src] = img
+
+	data, err := l.doc.fetch(src)
+	if err != nil {
+		return img
+	}
+	var imageType string
+	switch http.DetectContentType(data) {
+	case "image/png":
+		imageType = "PNG"
+	case "image/jpeg":
+		imageType = "JPG"
+	case "image/gif":
+		imageType = "GIF"
+	default:
+		img.err = fmt.Errorf("%w: image format of %s (only PNG, JPEG and GIF are supported)", ErrUnsupported, truncate(src, 50))
+		return img
+	}
+
+	name := fmt.Sprintf("image%d", len(l.images))
+	info := l.f.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: imageType}, bytes.NewReader(data))
+	if err := l.f.Error(); err != nil {
+		img.err = fmt.Errorf("load image %s: %w", truncate(src, 50), err)
+		return img
+	}
+	img.name = name
+	// fpdf assumes 72 dpi, browsers 96 dpi
+	img.width = info.Width() * 72 / 96
+	img.height = info.Height() * 72 / 96
+	return img
+}
+
+// cell is a table cell
+type cell struct {
+	node    *html.Node
+	style   *style
+	column  int
+	colspan int
+}
+
+// table lays out a table. Rows are never split across pages.
+func (l *layout) table(n *html.Node, s *style, x, w float64) error {
+	bx, bw := l.beginBox(s, x, w)
+	startPage, startY, opIndex := l.page, l.y, len(l.ops)
+	l.y += s.border[0].width + s.padding[0]
+	cx := bx + s.border[3].width + s.padding[3]
+	cw := bw - s.border[3].width - s.padding[3] - s.padding[1] - s.border[1].width
+
+	// Collect the rows and cells
+	type row struct {
+		node  *html.Node
+		style *style
+		cells []cell
+	}
+	var rows []row
+	columns := 0
+	var collect func(n *html.Node, s *style) error
+	collect = func(n *html.Node, s *style) error {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.TextNode && strings.TrimSpace(c.Data) != "" {
+				return fmt.Errorf("%s: %w: text outside of table cells", path(n), ErrUnsupported)
+			}
+			if c.Type != html.ElementNode {
+				continue
+			}
+			cs, err := l.style(c, s)
+			if err != nil {
+				return err
+			}
+			switch cs.display {
+			case "none":
+			case "table-row-group":
+				if err := collect(c, cs); err != nil {
+					return err
+				}
+			case "table-row":
+				r := row{node: c, style: cs}
+				column := 0
+				for cc := c.FirstChild; cc != nil; cc = cc.NextSibling {
+					if cc.Type == html.TextNode && strings.TrimSpace(cc.Data) != "" {
+						return fmt.Errorf("%s: %w: text outside of table cells", path(c), ErrUnsupported)
+					}
+					if cc.Type != html.ElementNode {
+						continue
+					}
+					ccs, err := l.style(cc, cs)
+					if err != nil {
+						return err
+					}
+					if ccs.display == "none" {
+						continue
+					}
+					if ccs.display != "table-cell" {
+						return fmt.Errorf("%s: %w: <%s> in table row", path(cc), ErrUnsupported, cc.Data)
+					}
+					if rowspan := attr(cc, "rowspan"); rowspan != "" && rowspan != "1" {
+						return fmt.Errorf("%s: %w: rowspan", path(cc), ErrUnsupported)
+					}
+					colspan := 1
+					if v := attr(cc, "colspan"); v != "" {
+						if colspan, err = strconv.Atoi(v); err != nil || colspan < 1 {
+							return fmt.Errorf("%s: invalid colspan %q", path(cc), v)
+						}
+					}
+					r.cells = append(r.cells, cell{node: cc, style: ccs, column: column, colspan: colspan})
+					column += colspan
+				}
+				columns = max(columns, column)
+				rows = append(rows, r)
+			default:
+				return fmt.Errorf("%s: %w: <%s> in table", path(c), ErrUnsupported, c.Data)
+			}
+		}
+		return nil
+	}
+	if err := collect(n, s); err != nil {
+		return err
+	}
+
+	// Column widths are taken from the first cell of each column with a
+	// width; the remaining width is distributed evenly
+	widths := make([]float64, columns)
+	set := make([]bool, columns)
+	for _, r := range rows {
+		for _, c := range r.cells {
+			if c.colspan == 1 && c.style.width.set && !set[c.column] {
+				cs := c.style
+				widths[c.column] = cs.width.resolve(cw) + cs.padding[3] + cs.padding[1] + cs.border[3].width + cs.border[1].width
+				set[c.column] = true
+			}
+		}
+	}
+	fixed, unset := 0.0, 0
+	for i := range widths {
+		if set[i] {
+			fixed += widths[i]
+		} else {
+			unset++
+		}
+	}
+	for i := range widths {
+		switch {
+		case fixed > cw:
+			widths[i] *= cw / fixed
+		case !set[i]:
+			widths[i] = (cw - fixed) / float64(unset)
+		}
+	}
+
+	// Lay out the rows
+	for _, r := range rows {
+		layoutCell := func(c cell, top, offset float64) error {
+			x := cx
+			for _, w := range widths[:c.column] {
+				x += w
+			}
+			w := 0.0
+			for _, cw := range widths[c.column:min(c.column+c.colspan, columns)] {
+				w += cw
+			}
+			cs := c.style
+			l.y = top + offset + cs.border[0].width + cs.padding[0]
+			l.prevMargin = 0
+			contentTop := l.y
+			err := l.contents(c.node, cs, x+cs.border[3].width+cs.padding[3], w-cs.border[3].width-cs.padding[3]-cs.padding[1]-cs.border[1].width)
+			if cs.height.set && !cs.height.percent && l.y-contentTop < cs.height.value {
+				l.y = contentTop + cs.height.value
+			}
+			l.y += cs.padding[2] + cs.border[2].width
+			return err
+		}
+
+		// Measure the cells to find the height of the row
+		top := l.y
+		heights := make([]float64, len(r.cells))
+		rowHeight := 0.0
+		if r.style.height.set && !r.style.height.percent {
+			rowHeight = r.style.height.value
+		}
+		for i, c := range r.cells {
+			h, err := l.measure(func() error { return layoutCell(c, top, 0) })
+			if err != nil {
+				return err
+			}
+			heights[i] = h
+			rowHeight = max(rowHeight, h)
+		}
+
+		l.ensure(rowHeight)
+		top = l.y
+		rowIndex := len(l.ops)
+		noBreak := l.noBreak
+		l.noBreak = true
+		for i, c := range r.cells {
+			offset := 0.0
+			switch c.style.verticalAlign {
+			case "middle":
+				offset = (rowHeight - heights[i]) / 2
+			case "bottom":
+				offset = rowHeight - heights[i]
+			}
+			cellIndex := len(l.ops)
+			if err := layoutCell(c, top, offset); err != nil {
+				return err
+			}
+			x := cx
+			for _, w := range widths[:c.column] {
+				x += w
+			}
+			w := 0.0
+			for _, cw := range widths[c.column:min(c.column+c.colspan, columns)] {
+				w += cw
+			}
+			l.y = top + rowHeight
+			l.decorate(cellIndex, c.style, x, w, l.page, top)
+		}
+		l.noBreak = noBreak
+		l.y = top + rowHeight
+		l.decorate(rowIndex, r.style, cx, cw, l.page, top)
+	}
+
+	l.y += s.padding[2] + s.border[2].width
+	l.decorate(opIndex, s, bx, bw, startPage, startY)
+	l.endBox(s)
+	return nil
+}
+
+// measure runs fn without page breaks and returns the height of the laid out
+// content. The layout is restored afterwards.
+func (l *layout) measure(fn func() error) (float64, error) {
+	page, pages, y, ops := l.page, l.pages, l.y, len(l.ops)
+	prevMargin, marker, noBreak := l.prevMargin, l.marker, l.noBreak
+
+	l.noBreak = true
+	err := fn()
+	height := l.y - y
+
+	l.page, l.pages, l.y, l.ops = page, pages, y, l.ops[:ops]
+	l.prevMargin, l.marker, l.noBreak = prevMargin, marker, noBreak
+	return height, err
+}
+
+// decorate inserts the background and borders of a box below its content,
+// which starts at the operation at index. Boxes spanning multiple pages are
+// decorated on each page.
+func (l *layout) decorate(index int, s *style, x, w float64, startPage int, startY float64) {
+	if s.background == nil && s.border == [4]border{} {
+		return
+	}
+
+	var ops []op
+	for page := startPage; page <= l.page; page++ {
+		top, bottom := l.top(), l.bottom()
+		if page == startPage {
+			top = startY
+		}
+		if page == l.page {
+			bottom = l.y
+		}
+		rect := func(x, y, w, h float64, c rgb) {
+			if w > 0 && h > 0 {
+				ops = append(ops, op{page: page, kind: opRect, x: x, y: y, w: w, h: h, color: c})
+			}
+		}
+
+		if s.background != nil {
+			rect(x, top, w, bottom-top, *s.background)
+		}
+		b := s.border
+		if page == startPage {
+			rect(x, top, w, b[0].width, b[0].color)
+		}
+		if page == l.page {
+			rect(x, bottom-b[2].width, w, b[2].width, b[2].color)
+		}
+		rect(x, top, b[3].width, bottom-top, b[3].color)
+		rect(x+w-b[1].width, top, b[1].width, bottom-top, b[1].color)
+	}
+
+	l.ops = slices.Insert(l.ops, index, ops...)
+}
+
+// paint draws the operations on the pages of the PDF
+func (l *layout) paint() {
+	slices.SortStableFunc(l.ops, func(a, b op) int {
+		return a.page - b.page
+	})
+
+	i := 0
+	for page := range l.pages {
+		l.f.AddPage()
+		for ; i < len(l.ops) && l.ops[i].page == page; i++ {
+			o := l.ops[i]
+			switch o.kind {
+			case opText:
+				l.f.SetFont(o.font, o.fontStyle, o.fontSize)
+				l.f.SetTextColor(o.color.r, o.color.g, o.color.b)
+				l.f.Text(o.x, o.y, o.text)
+			case opRect:
+				l.f.SetFillColor(o.color.r, o.color.g, o.color.b)
+				l.f.Rect(o.x, o.y, o.w, o.h, "F")
+			case opImage:
+				l.f.ImageOptions(o.image, o.x, o.y, o.w, o.h, false, fpdf.ImageOptions{}, 0, "")
+			}
+		}
+	}
+}
+
+// path describes the position of the element for error messages, e.g.
+// body > div.items > table
+func path(n *html.Node) string {
+	var parts []string
+	for ; n != nil && n.Type == html.ElementNode; n = n.Parent {
+		part := n.Data
+		if id := attr(n, "id"); id != "" {
+			part += "#" + id
+		} else if class := strings.Fields(attr(n, "class")); len(class) > 0 {
+			part += "." + strings.Join(class, ".")
+		}
+		parts = append(parts, part)
+		if n.Data == "body" {
+			break
+		}
+	}
+	slices.Reverse(parts)
+	return strings.Join(parts, " > ")
+}
@@ -0,0 +1,833 @@
+package pdf
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Supported by the native renderer: font families of the built-in PDF fonts
+var nativeFontFamilies = map[string]string{
+	"helvetica":       "Helvetica",
+	"arial":           "Helvetica",
+	"sans-serif":      "Helvetica",
+	"times":           "Times",
+	"times new roman": "Times",
+	"serif":           "Times",
+	"courier":         "Courier",
+	"courier new":     "Courier",
+	"monospace":       "Courier",
+}
+
+// Supported by the native renderer: named colors
+var nativeColors = map[string]rgb{
+	"black":  {0, 0, 0},
+	"white":  {255, 255, 255},
+	"red":    {255, 0, 0},
+	"green":  {0, 128, 0},
+	"blue":   {0, 0, 255},
+	"gray":   {128, 128, 128},
+	"grey":   {128, 128, 128},
+	"silver": {192, 192, 192},
+	"navy":   {0, 0, 128},
+	"maroon": {128, 0, 0},
+	"orange": {255, 165, 0},
+}
+
+// Supported by the native renderer: font size keywords, in pt
+var nativeFontSizes = map[string]float64{
+	"xx-small": 7,
+	"x-small":  7.5,
+	"small":    10,
+	"medium":   12,
+	"large":    13.5,
+	"x-large":  18,
+	"xx-large": 24,
+}
+
+// rgb is a color
+type rgb struct {
+	r, g, b int
+}
+
+// length is a CSS length, either in mm or as percentage of the containing
+// block
+type length struct {
+	value   float64
+	percent bool
+	set     bool
+}
+
+// resolve returns the length in mm, relative to the width of the containing
+// block
+func (l length) resolve(containing float64) float64 {
+	if l.percent {
+		return l.value / 100 * containing
+	}
+	return l.value
+}
+
+// border is one side of a box's border
+type border struct {
+	width float64
+	color rgb
+}
+
+// style is the computed style of an element. Sizes are in mm, except for
+// font sizes in pt.
+type style struct {
+	// inherited
+	fontFamily string
+	bold       bool
+	italic     bool
+	underline  bool
+	fontSize   float64
+	color      rgb
+	textAlign  string
+	lineHeight float64
+
+	// not inherited
+	display         string
+	margin          [4]float64
+	padding         [4]float64
+	border          [4]border
+	background      *rgb
+	width           length
+	height          length
+	verticalAlign   string
+	pageBreakBefore bool
+	pageBreakAfter  bool
+}
+
+// inherit returns the initial style of a child element
+func (s *style) inherit(display string) *style {
+	return &style{
+		fontFamily: s.fontFamily,
+		bold:       s.bold,
+		italic:     s.italic,
+		underline:  s.underline,
+		fontSize:   s.fontSize,
+		color:      s.color,
+		textAlign:  s.textAlign,
+		lineHeight: s.lineHeight,
+		display:    display,
+	}
+}
+
+// fontStyle returns the font style for fpdf
+func (s *style) fontStyle() string {
+	var fs string
+	if s.bold {
+		fs += "B"
+	}
+	if s.italic {
+		fs += "I"
+	}
+	return fs
+}
+
+// fontSizeMM returns the font size in mm
+func (s *style) fontSizeMM() float64 {
+	return s.fontSize * mmPerPt
+}
+
+const (
+	mmPerPt = 25.4 / 72
+	mmPerPx = 25.4 / 96
+)
+
+// rootStyle is the style of the html element
+func rootStyle() *style {
+	return &style{
+		fontFamily: "Times",
+		fontSize:   12,
+		textAlign:  "left",
+		lineHeight: 1.2,
+		display:    "block",
+	}
+}
+
+// nativeElements lists the supported elements with their display type and
+// default styles (the user agent stylesheet)
+var nativeElements = map[string]struct {
+	display string
+	css     string
+}{
+	"html":       {"block", ""},
+	"body":       {"block", "margin: 8px"},
+	"div":        {"block", ""},
+	"header":     {"block", ""},
+	"footer":     {"block", ""},
+	"main":       {"block", ""},
+	"section":    {"block", ""},
+	"article":    {"block", ""},
+	"address":    {"block", "font-style: italic"},
+	"blockquote": {"block", "margin: 1em 40px"},
+	"p":          {"block", "margin: 1em 0"},
+	"h1":         {"block", "font-size: 2em; font-weight: bold; margin: 0.67em 0"},
+	"h2":         {"block", "font-size: 1.5em; font-weight: bold; margin: 0.83em 0"},
+	"h3":         {"block", "font-size: 1.17em; font-weight: bold; margin: 1em 0"},
+	"h4":         {"block", "font-weight: bold; margin: 1.33em 0"},
+	"h5":         {"block", "font-size: 0.83em; font-weight: bold; margin: 1.67em 0"},
+	"h6":         {"block", "font-size: 0.67em; font-weight: bold; margin: 2.33em 0"},
+	"ul":         {"block", "margin: 1em 0; padding-left: 40px"},
+	"ol":         {"block", "margin: 1em 0; padding-left: 40px"},
+	"li":         {"list-item", ""},
+	"hr":         {"block", "margin: 0.5em 0; border-top: 1px solid gray"},
+	"table":      {"table", ""},
+	"thead":      {"table-row-group", ""},
+	"tbody":      {"table-row-group", ""},
+	"tfoot":      {"table-row-group", ""},
+	"tr":         {"table-row", ""},
+	"td":         {"table-cell", "padding: 1px"},
+	"th":         {"table-cell", "padding: 1px; font-weight: bold; text-align: center"},
+	"img":        {"image", ""},
+	"br":         {"break", ""},
+	"span":       {"inline", ""},
+	"a":          {"inline", "color: #0000ee; text-decoration: underline"},
+	"strong":     {"inline", "font-weight: bold"},
+	"b":          {"inline", "font-weight: bold"},
+	"em":         {"inline", "font-style: italic"},
+	"i":          {"inline", "font-style: italic"},
+	"u":          {"inline", "text-decoration: underline"},
+	"small":      {"inline", "font-size: 0.83em"},
+	"code":       {"inline", "font-family: monospace"},
+}
+
+// declaration is a CSS property and its value
+type declaration struct {
+	property string
+	value    string
+}
+
+// rule is a CSS rule with a single simple selector
+type rule struct {
+	selector     simpleSelector
+	specificity  int
+	declarations []declaration
+}
+
+// simpleSelector matches elements by type, id and classes, e.g. td.total
+type simpleSelector struct {
+	tag     string
+	id      string
+	classes []string
+}
+
+var simpleSelectorPattern = regexp.MustCompile(`^(\*|[a-zA-Z][a-zA-Z0-9]*)?((?:[.#][a-zA-Z_-][a-zA-Z0-9_-]*)*)$`)
+var selectorPartPattern = regexp.MustCompile(`[.#][a-zA-Z_-][a-zA-Z0-9_-]*`)
+
+// parseSelector parses a simple selector
+func parseSelector(s string) (simpleSelector, int, error) {
+	m := simpleSelectorPattern.FindStringSubmatch(s)
+	if m == nil || s == "" {
+		return simpleSelector{}, 0, fmt.Errorf("%w: selector %q (only type, class and id selectors are supported)", ErrUnsupported, s)
+	}
+
+	sel := simpleSelector{tag: strings.ToLower(m[1])}
+	specificity := 0
+	if sel.tag == "*" {
+		sel.tag = ""
+	} else if sel.tag != "" {
+		specificity++
+	}
+	for _, part := range selectorPartPattern.FindAllString(m[2], -1) {
+		if part[0] == '#' {
+			sel.id = part[1:]
+			specificity += 100
+		} else {
+			sel.classes = append(sel.classes, part[1:])
+			specificity += 10
+		}
+	}
+	return sel, specificity, nil
+}
+
+// matches reports whether the selector matches the element
+func (sel simpleSelector) matches(n *html.Node) bool {
+	if sel.tag != "" && sel.tag != n.Data {
+		return false
+	}
+	if sel.id != "" && attr(n, "id") != sel.id {
+		return false
+	}
+	classes := strings.Fields(attr(n, "class"))
+	for _, c := range sel.classes {
+		found := false
+		for _, have := range classes {
+			if have == c {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// stylesheet is a parsed CSS stylesheet
+type stylesheet struct {
+	rules []rule
+	// pageMargin is the margin of @page rules
+	pageMargin []declaration
+}
+
+var cssComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// parse adds the rules of the CSS source to the stylesheet
+func (ss *stylesheet) parse(css string) error {
+	css = cssComment.ReplaceAllString(css, "")
+	for {
+		css = strings.TrimSpace(css)
+		if css == "" {
+			return nil
+		}
+		open := strings.Index(css, "{")
+		end := strings.Index(css, "}")
+		if open < 0 || end < open {
+			return fmt.Errorf("invalid CSS near %q", truncate(css, 30))
+		}
+		prelude := strings.TrimSpace(css[:open])
+
+		if query, ok := strings.CutPrefix(prelude, "@media"); ok {
+			// @media blocks contain rules, so they end at the matching brace
+			end := matchingBrace(css, open)
+			if end < 0 {
+				return fmt.Errorf("invalid CSS near %q", truncate(css, 30))
+			}
+			body := css[open+1 : end]
+			css = css[end+1:]
+			applies, err := mediaPrint(query)
+			if err != nil {
+				return err
+			}
+			if applies {
+				if err := ss.parse(body); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		body := css[open+1 : end]
+		css = css[end+1:]
+
+		decls, err := parseDeclarations(body)
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(prelude, "@") {
+			if prelude != "@page" {
+				return fmt.Errorf("%w: at-rule %s", ErrUnsupported, prelude)
+			}
+			for _, d := range decls {
+				switch d.property {
+				case "margin", "margin-top", "margin-right", "margin-bottom", "margin-left":
+					ss.pageMargin = append(ss.pageMargin, d)
+				case "size":
+					// The page size is configured by the template
+				default:
+					return fmt.Errorf("%w: property %s in @page", ErrUnsupported, d.property)
+				}
+			}
+			continue
+		}
+
+		for _, s := range strings.Split(prelude, ",") {
+			sel, specificity, err := parseSelector(strings.TrimSpace(s))
+			if err != nil {
+				return err
+			}
+			ss.rules = append(ss.rules, rule{
+				selector:     sel,
+				specificity:  specificity,
+				declarations: decls,
+			})
+		}
+	}
+}
+
+// matchingBrace returns the index of the brace closing the one at open, or -1
+// if it isn't closed
+func matchingBrace(css string, open int) int {
+	depth := 0
+	for i := open; i < len(css); i++ {
+		switch css[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// mediaPrint reports whether the media query list applies to print. Media
+// features can't be evaluated, so queries for print with features are
+// unsupported.
+func mediaPrint(query string) (bool, error) {
+	for _, q := range strings.Split(query, ",") {
+		words := strings.Fields(strings.ToLower(q))
+		if len(words) > 0 && words[0] == "only" {
+			words = words[1:]
+		}
+		not := len(words) > 0 && words[0] == "not"
+		if not {
+			words = words[1:]
+		}
+		mediaType := "all"
+		if len(words) > 0 && !strings.HasPrefix(words[0], "(") {
+			mediaType, words = words[0], words[1:]
+		}
+		if (mediaType == "print" || mediaType == "all") == not {
+			continue
+		}
+		if len(words) > 0 {
+			return false, fmt.Errorf("%w: media query %q", ErrUnsupported, strings.TrimSpace(q))
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// sort orders the rules by specificity, keeping the source order for rules
+// of equal specificity
+func (ss *stylesheet) sort() {
+	sort.SliceStable(ss.rules, func(i, j int) bool {
+		return ss.rules[i].specificity < ss.rules[j].specificity
+	})
+}
+
+// parseDeclarations parses a CSS declaration block
+func parseDeclarations(css string) ([]declaration, error) {
+	var decls []declaration
+	for _, d := range strings.Split(css, ";") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		property, value, ok := strings.Cut(d, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid CSS declaration %q", d)
+		}
+		value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "!important"))
+		decls = append(decls, declaration{
+			property: strings.ToLower(strings.TrimSpace(property)),
+			value:    value,
+		})
+	}
+	return decls, nil
+}
+
+// computeStyle computes the style of the element from its parent's style, the
+// default styles, the stylesheet and its style attribute
+func (ss *stylesheet) computeStyle(n *html.Node, parent *style) (*style, error) {
+	el, ok := nativeElements[n.Data]
+	if !ok {
+		return nil, fmt.Errorf("%w: element <%s>", ErrUnsupported, n.Data)
+	}
+
+	s := parent.inherit(el.display)
+	defaults, _ := parseDeclarations(el.css)
+	if err := s.apply(defaults, parent); err != nil {
+		return nil, err
+	}
+	for _, r := range ss.rules {
+		if r.selector.matches(n) {
+			if err := s.apply(r.declarations, parent); err != nil {
+				return nil, err
+			}
+		}
+	}
+	inline, err := parseDeclarations(attr(n, "style"))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apply(inline, parent); err != nil {
+		return nil, err
+	}
+
+	// Size attributes, e.g. of images and table cells
+	for i, name := range []string{"width", "height"} {
+		if v := attr(n, name); v != "" {
+			l, err := parseLength(v, s.fontSize, true)
+			if err != nil {
+				return nil, err
+			}
+			if dim := [2]*length{&s.width, &s.height}[i]; !dim.set {
+				*dim = l
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// apply applies the declarations to the style
+func (s *style) apply(decls []declaration, parent *style) error {
+	for _, d := range decls {
+		if err := s.set(d, parent); err != nil {
+			return fmt.Errorf("%s: %w", d.property, err)
+		}
+	}
+	return nil
+}
+
+// set applies a single declaration to the style
+func (s *style) set(d declaration, parent *style) error {
+	v := strings.ToLower(d.value)
+	var err error
+	switch d.property {
+	case "color":
+		s.color, err = parseColor(v)
+	case "background", "background-color":
+		if v == "none" || v == "transparent" {
+			s.background = nil
+			return nil
+		}
+		var c rgb
+		c, err = parseColor(v)
+		s.background = &c
+	case "font-family":
+		s.fontFamily, err = parseFontFamily(d.value)
+	case "font-size":
+		s.fontSize, err = parseFontSize(v, parent.fontSize)
+	case "font-weight":
+		switch v {
+		case "bold", "bolder", "600", "700", "800", "900":
+			s.bold = true
+		case "normal", "lighter", "100", "200", "300", "400", "500":
+			s.bold = false
+		default:
+			err = fmt.Errorf("%w: value %q", ErrUnsupported, v)
+		}
+	case "font-style":
+		switch v {
+		case "italic", "oblique":
+			s.italic = true
+		case "normal":
+			s.italic = false
+		default:
+			err = fmt.Errorf("%w: value %q", ErrUnsupported, v)
+		}
+	case "text-decoration", "text-decoration-line":
+		switch v {
+		case "underline":
+			s.underline = true
+		case "none":
+			s.underline = false
+		default:
+			err = fmt.Errorf("%w: value %q", ErrUnsupported, v)
+		}
+	case "text-align":
+		switch v {
+		case "left", "start":
+			s.textAlign = "left"
+		case "center", "right":
+			s.textAlign = v
+		case "end":
+			s.textAlign = "right"
+		default:
+			err = fmt.Errorf("%w: value %q", ErrUnsupported, v)
+		}
+	case "line-height":
+		s.lineHeight, err = parseLineHeight(v, s.fontSize)
+	case "display":
+		switch {
+		case v == "none":
+			s.display = "none"
+		case v != s.display:
+			err = fmt.Errorf("%w: changing display to %q", ErrUnsupported, v)
+		}
+	case "margin", "padding":
+		sides := &s.margin
+		if d.property == "padding" {
+			sides = &s.padding
+		}
+		*sides, err = parseSides(v, s.fontSize)
+	case "margin-top", "margin-right", "margin-bottom", "margin-left":
+		s.margin[side(d.property)], err = parseMM(v, s.fontSize)
+	case "padding-top", "padding-right", "padding-bottom", "padding-left":
+		s.padding[side(d.property)], err = parseMM(v, s.fontSize)
+	case "border":
+		var b border
+		if b, err = parseBorder(v, s.fontSize); err == nil {
+			s.border = [4]border{b, b, b, b}
+		}
+	case "border-top", "border-right", "border-bottom", "border-left":
+		s.border[side(d.property)], err = parseBorder(v, s.fontSize)
+	case "border-color":
+		var c rgb
+		if c, err = parseColor(v); err == nil {
+			for i := range s.border {
+				s.border[i].color = c
+			}
+		}
+	case "border-width":
+		var w float64
+		if w, err = parseMM(v, s.fontSize); err == nil {
+			for i := range s.border {
+				s.border[i].width = w
+			}
+		}
+	case "width":
+		s.width, err = parseLength(v, s.fontSize, false)
+	case "height":
+		s.height, err = parseLength(v, s.fontSize, false)
+	case "vertical-align":
+		switch v {
+		case "top", "middle", "bottom":
+			s.verticalAlign = v
+		default:
+			err = fmt.Errorf("%w: value %q", ErrUnsupported, v)
+		}
+	case "page-break-before", "break-before", "page-break-after", "break-after":
+		var brk bool
+		switch v {
+		case "always", "page":
+			brk = true
+		case "auto":
+		default:
+			err = fmt.Errorf("%w: value %q", ErrUnsupported, v)
+		}
+		if strings.HasSuffix(d.property, "before") {
+			s.pageBreakBefore = brk
+		} else {
+			s.pageBreakAfter = brk
+		}
+	default:
+		return ErrUnsupported
+	}
+	return err
+}
+
+// side returns the index of the side named by the property suffix
+func side(property string) int {
+	switch {
+	case strings.HasSuffix(property, "-top"):
+		return 0
+	case strings.HasSuffix(property, "-right"):
+		return 1
+	case strings.HasSuffix(property, "-bottom"):
+		return 2
+	default:
+		return 3
+	}
+}
+
+// parseSides parses the 1 to 4 values of a margin or padding shorthand
+func parseSides(v string, fontSize float64) ([4]float64, error) {
+	var values []float64
+	for _, f := range strings.Fields(v) {
+		mm, err := parseMM(f, fontSize)
+		if err != nil {
+			return [4]float64{}, err
+		}
+		values = append(values, mm)
+	}
+	switch len(values) {
+	case 1:
+		return [4]float64{values[0], values[0], values[0], values[0]}, nil
+	case 2:
+		return [4]float64{values[0], values[1], values[0], values[1]}, nil
+	case 3:
+		return [4]float64{values[0], values[1], values[2], values[1]}, nil
+	case 4:
+		return [4]float64{values[0], values[1], values[2], values[3]}, nil
+	}
+	return [4]float64{}, fmt.Errorf("invalid value %q", v)
+}
+
+// parseBorder parses a border shorthand, e.g. "1px solid #000"
+func parseBorder(v string, fontSize float64) (border, error) {
+	b := border{width: 3 * mmPerPx}
+	if v == "none" || v == "0" {
+		return border{}, nil
+	}
+	for _, f := range strings.Fields(v) {
+		switch {
+		case f == "solid":
+		case f == "none":
+			return border{}, nil
+		case f == "thin":
+			b.width = mmPerPx
+		case f == "medium":
+			b.width = 3 * mmPerPx
+		case f == "thick":
+			b.width = 5 * mmPerPx
+		case f[0] >= '0' && f[0] <= '9' || f[0] == '.':
+			w, err := parseMM(f, fontSize)
+			if err != nil {
+				return border{}, err
+			}
+			b.width = w
+		default:
+			c, err := parseColor(f)
+			if err != nil {
+				return border{}, fmt.Errorf("%w: border value %q (only solid borders are supported)", ErrUnsupported, f)
+			}
+			b.color = c
+		}
+	}
+	return b, nil
+}
+
+// parseMM parses a length in mm; percentages are not allowed
+func parseMM(v string, fontSize float64) (float64, error) {
+	l, err := parseLength(v, fontSize, false)
+	if err != nil {
+		return 0, err
+	}
+	if l.percent {
+		return 0, fmt.Errorf("%w: percentage %q", ErrUnsupported, v)
+	}
+	return l.value, nil
+}
+
+var lengthPattern = regexp.MustCompile(`^(-?[0-9]*\.?[0-9]+)(px|pt|mm|cm|in|em|rem|%)?$`)
+
+// parseLength parses a CSS length. Unitless values are only allowed for
+// zero, or for HTML attributes, where they are pixels.
+func parseLength(v string, fontSize float64, attribute bool) (length, error) {
+	if v == "auto" {
+		return length{}, nil
+	}
+	m := lengthPattern.FindStringSubmatch(v)
+	if m == nil {
+		return length{}, fmt.Errorf("%w: length %q", ErrUnsupported, v)
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return length{}, fmt.Errorf("invalid length %q", v)
+	}
+
+	l := length{set: true}
+	switch m[2] {
+	case "":
+		if n != 0 && !attribute {
+			return length{}, fmt.Errorf("length %q without unit", v)
+		}
+		l.value = n * mmPerPx
+	case "px":
+		l.value = n * mmPerPx
+	case "pt":
+		l.value = n * mmPerPt
+	case "mm":
+		l.value = n
+	case "cm":
+		l.value = n * 10
+	case "in":
+		l.value = n * 25.4
+	case "em":
+		l.value = n * fontSize * mmPerPt
+	case "rem":
+		l.value = n * rootStyle().fontSize * mmPerPt
+	case "%":
+		l.value = n
+		l.percent = true
+	}
+	return l, nil
+}
+
+// parseFontSize parses a font size relative to the parent's font size, in pt
+func parseFontSize(v string, parent float64) (float64, error) {
+	if size, ok := nativeFontSizes[v]; ok {
+		return size, nil
+	}
+	switch v {
+	case "smaller":
+		return parent / 1.2, nil
+	case "larger":
+		return parent * 1.2, nil
+	}
+	l, err := parseLength(v, parent, false)
+	if err != nil {
+		return 0, err
+	}
+	if l.percent {
+		return l.value / 100 * parent, nil
+	}
+	return l.value / mmPerPt, nil
+}
+
+// parseLineHeight parses a line height as factor of the font size
+func parseLineHeight(v string, fontSize float64) (float64, error) {
+	if v == "normal" {
+		return 1.2, nil
+	}
+	if n, err := strconv.ParseFloat(v, 64); err == nil {
+		return n, nil
+	}
+	l, err := parseLength(v, fontSize, false)
+	if err != nil {
+		return 0, err
+	}
+	if l.percent {
+		return l.value / 100, nil
+	}
+	return l.value / (fontSize * mmPerPt), nil
+}
+
+// parseFontFamily returns the first supported font family of the list
+func parseFontFamily(v string) (string, error) {
+	for _, f := range strings.Split(v, ",") {
+		f = strings.ToLower(strings.Trim(strings.TrimSpace(f), `"'`))
+		if family, ok := nativeFontFamilies[f]; ok {
+			return family, nil
+		}
+	}
+	return "", fmt.Errorf("%w: font family %q (only Helvetica, Times and Courier are available)", ErrUnsupported, v)
+}
+
+var rgbPattern = regexp.MustCompile(`^rgb\(\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d+)\s*\)$`)
+
+// parseColor parses a hex, rgb() or named color
+func parseColor(v string) (rgb, error) {
+	if c, ok := nativeColors[v]; ok {
+		return c, nil
+	}
+	if m := rgbPattern.FindStringSubmatch(v); m != nil {
+		r, _ := strconv.Atoi(m[1])
+		g, _ := strconv.Atoi(m[2])
+		b, _ := strconv.Atoi(m[3])
+		return rgb{min(r, 255), min(g, 255), min(b, 255)}, nil
+	}
+	if strings.HasPrefix(v, "#") {
+		hex := v[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if n, err := strconv.ParseUint(hex, 16, 32); err == nil && len(hex) == 6 {
+			return rgb{int(n >> 16 & 0xff), int(n >> 8 & 0xff), int(n & 0xff)}, nil
+		}
+	}
+	return rgb{}, fmt.Errorf("%w: color %q", ErrUnsupported, v)
+}
+
+// attr returns the value of the element's attribute, or an empty string
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// truncate shortens s to n bytes for error messages
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "…"
+}
@@ -0,0 +1,223 @@
+package pdf_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/sehrgutesoftware/httpdf/internal/pdf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pngPixel is a 1x1 PNG image
+const pngPixel = "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mP8z8DwHwAFBQIAX8jx0gAAAABJRU5ErkJggg=="
+
+// pageContent returns the content stream of each page of the PDF
+func pageContent(t *testing.T, document []byte) []string {
+	t.Helper()
+
+	ctx, err := api.ReadAndValidate(bytes.NewReader(document), model.NewDefaultConfiguration())
+	require.NoError(t, err)
+	var pages []string
+	for i := 1; i <= ctx.PageCount; i++ {
+		d, _, _, err := ctx.PageDict(i, false)
+		require.NoError(t, err)
+		content, err := ctx.PageContent(d, i)
+		require.NoError(t, err)
+		pages = append(pages, string(content))
+	}
+	return pages
+}
+
+func TestNativeRenderer_Render(t *testing.T) {
+	ctx := context.Background()
+	a4 := pdf.RenderOpts{Width: 210, Height: 297}
+
+	t.Run("it_renders_text_in_a_pdf_of_the_given_page_size", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><head><style>
+			h1 { font-family: Arial; color: #336699 }
+			.total { font-weight: bold; text-align: right }
+		</style></head><body>
+			<h1>Invoice</h1>
+			<p>Dear customer,</p>
+			<table><tr><td>Total</td><td class="total">42 €</td></tr></table>
+		</body></html>`})
+
+		var out bytes.Buffer
+		_, err := pdf.NewNativeRenderer().Render(ctx, srv.URL+"/", &out, a4)
+
+		require.NoError(t, err)
+		dims, err := api.PageDims(bytes.NewReader(out.Bytes()), nil)
+		require.NoError(t, err)
+		require.Len(t, dims, 1)
+		assert.InDelta(t, 595.3, dims[0].Width, 1)
+		assert.InDelta(t, 841.9, dims[0].Height, 1)
+		content := pageContent(t, out.Bytes())[0]
+		assert.Contains(t, content, "(Invoice)")
+		assert.Contains(t, content, "(Dear)")
+		assert.Contains(t, content, "( customer,)")
+		assert.Contains(t, content, "(42)")
+	})
+
+	t.Run("it_breaks_pages", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><body>
+			<p>First</p>
+			<p style="page-break-before: always">Second</p>
+			<div style="height: 400mm"></div>
+		</body></html>`})
+
+		var out bytes.Buffer
+		_, err := pdf.NewNativeRenderer().Render(ctx, srv.URL+"/", &out, a4)
+
+		require.NoError(t, err)
+		pages := pageContent(t, out.Bytes())
+		require.Len(t, pages, 3)
+		assert.Contains(t, pages[0], "(First)")
+		assert.Contains(t, pages[1], "(Second)")
+	})
+
+	t.Run("it_renders_images", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><body><img src="` + pngPixel + `" width="96"></body></html>`})
+
+		var out bytes.Buffer
+		_, err := pdf.NewNativeRenderer().Render(ctx, srv.URL+"/", &out, a4)
+
+		require.NoError(t, err)
+		assert.Contains(t, pageContent(t, out.Bytes())[0], "Do")
+	})
+
+	t.Run("it_reports_unsupported_elements", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><body><div class="chart"><svg></svg></div></body></html>`})
+
+		_, err := pdf.NewNativeRenderer().Render(ctx, srv.URL+"/", &bytes.Buffer{}, a4)
+
+		assert.ErrorIs(t, err, pdf.ErrUnsupported)
+		assert.ErrorContains(t, err, "div.chart > svg")
+	})
+
+	t.Run("it_reports_unsupported_properties", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><body><div style="display: flex"></div></body></html>`})
+
+		_, err := pdf.NewNativeRenderer().Render(ctx, srv.URL+"/", &bytes.Buffer{}, a4)
+
+		assert.ErrorIs(t, err, pdf.ErrUnsupported)
+		assert.ErrorContains(t, err, "display")
+	})
+
+	t.Run("it_skips_media_rules_not_applying_to_print", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><head><style>
+			@media screen {
+				@supports (display: grid) { .page { display: grid } }
+				.page { box-shadow: 0 0 5mm rgba(0, 0, 0, 0.2) }
+			}
+			@media print { p { color: #333 } }
+			p { margin: 2mm 0 }
+		</style></head><body><p class="page">Hello</p></body></html>`})
+
+		_, err := pdf.NewNativeRenderer().Render(ctx, srv.URL+"/", &bytes.Buffer{}, a4)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("it_applies_media_rules_for_print", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><head><style>
+			@media only print, screen { p { display: flex } }
+		</style></head><body><p>Hello</p></body></html>`})
+
+		_, err := pdf.NewNativeRenderer().Render(ctx, srv.URL+"/", &bytes.Buffer{}, a4)
+
+		assert.ErrorIs(t, err, pdf.ErrUnsupported)
+		assert.ErrorContains(t, err, "display")
+	})
+
+	t.Run("it_reports_media_features_as_unsupported", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><head><style>
+			@media (max-width: 600px) { p { color: red } }
+		</style></head><body><p>Hello</p></body></html>`})
+
+		_, err := pdf.NewNativeRenderer().Render(ctx, srv.URL+"/", &bytes.Buffer{}, a4)
+
+		assert.ErrorIs(t, err, pdf.ErrUnsupported)
+		assert.ErrorContains(t, err, "max-width")
+	})
+
+	t.Run("it_reports_unsupported_selectors", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><head><style>
+			table > tr { color: red }
+		</style></head><body></body></html>`})
+
+		_, err := pdf.NewNativeRenderer().Render(ctx, srv.URL+"/", &bytes.Buffer{}, a4)
+
+		assert.ErrorIs(t, err, pdf.ErrUnsupported)
+	})
+
+	t.Run("it_reports_scripts_as_unsupported", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><body><script>document.title = "x"</script></body></html>`})
+
+		_, err := pdf.NewNativeRenderer().Render(ctx, srv.URL+"/", &bytes.Buffer{}, a4)
+
+		assert.ErrorIs(t, err, pdf.ErrUnsupported)
+	})
+
+	t.Run("it_reports_characters_outside_the_builtin_fonts", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><body>こんにちは</body></html>`})
+
+		_, err := pdf.NewNativeRenderer().Render(ctx, srv.URL+"/", &bytes.Buffer{}, a4)
+
+		assert.ErrorIs(t, err, pdf.ErrUnsupported)
+	})
+
+	t.Run("it_rejects_wait_conditions", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><body></body></html>`})
+		opts := a4
+		opts.Wait = []pdf.Wait{{Selector: "#chart"}}
+
+		_, err := pdf.NewNativeRenderer().Render(ctx, srv.URL+"/", &bytes.Buffer{}, opts)
+
+		assert.ErrorIs(t, err, pdf.ErrUnsupported)
+	})
+
+	t.Run("it_rejects_png_screenshots", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><body></body></html>`})
+		opts := a4
+		opts.Format = pdf.FormatPNG
+
+		_, err := pdf.NewNativeRenderer().Render(ctx, srv.URL+"/", &bytes.Buffer{}, opts)
+
+		assert.ErrorIs(t, err, pdf.ErrUnsupported)
+	})
+
+	t.Run("it_reports_missing_assets", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><body><img src="/missing.png"></body></html>`})
+
+		diagnostics, err := pdf.NewNativeRenderer().Render(ctx, srv.URL+"/", &bytes.Buffer{}, a4)
+
+		require.NoError(t, err)
+		require.Len(t, diagnostics, 1)
+		assert.True(t, strings.HasSuffix(diagnostics[0].URL, "/missing.png"))
+	})
+
+	t.Run("it_fails_on_missing_assets_in_strict_mode", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><body><img src="/missing.png"></body></html>`})
+		opts := a4
+		opts.Strict = true
+
+		_, err := pdf.NewNativeRenderer().Render(ctx, srv.URL+"/", &bytes.Buffer{}, opts)
+
+		assert.ErrorIs(t, err, pdf.ErrPageErrors)
+	})
+
+	t.Run("it_blocks_requests_outside_the_network_policy", func(t *testing.T) {
+		srv := servePages(t, map[string]string{"/": `<html><body><img src="http://example.invalid/logo.png"></body></html>`})
+
+		diagnostics, err := pdf.NewNativeRenderer().Render(ctx, srv.URL+"/", &bytes.Buffer{}, a4)
+
+		require.NoError(t, err)
+		require.Len(t, diagnostics, 1)
+		assert.Equal(t, "blocked by network policy", diagnostics[0].Message)
+	})
+}
@@ -0,0 +1,80 @@
+package pdf
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ErrBlockedWebSocket is returned if the page opens a WebSocket connection
+// outside the network policy. Chromium can't intercept WebSocket connections,
+// so the render fails instead.
+var ErrBlockedWebSocket = errors.New("WebSocket connection outside the network policy")
+
+// NetworkPolicy restricts the URLs a rendered page may request. Requests to
+// the origin of the rendered page, data: URLs and blob: URLs created by the
+// page are always allowed.
+type NetworkPolicy struct {
+	// Allow lists additional hosts or URL patterns. Hosts match any scheme
+	// and port, and may start with a wildcard, e.g. "*.example.com". URL
+	// patterns contain a scheme and may use * as wildcard, e.g.
+	// "https://cdn.example.com/fonts/*". A single "*" allows all requests.
+	Allow []string
+}
+
+// Allows reports whether the page served at origin may request rawURL
+func (p NetworkPolicy) Allows(origin, rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "data" {
+		return true
+	}
+	o, err := url.Parse(origin)
+	if err != nil {
+		o = &url.URL{}
+	}
+	if u.Scheme == "blob" {
+		// blob: URLs contain the origin of the page that created them
+		inner, err := url.Parse(u.Opaque)
+		return err == nil && inner.Host != "" && inner.Scheme == o.Scheme && inner.Host == o.Host
+	}
+	if o.Scheme == u.Scheme && o.Host == u.Host {
+		return true
+	}
+
+	for _, pattern := range p.Allow {
+		switch {
+		case pattern == "*":
+			return true
+		case strings.Contains(pattern, "://"):
+			if globMatch(pattern, rawURL) {
+				return true
+			}
+		case strings.HasPrefix(pattern, "*."):
+			if strings.HasSuffix(u.Hostname(), pattern[1:]) {
+				return true
+			}
+		case strings.EqualFold(pattern, u.Hostname()):
+			return true
+		}
+	}
+
+	return false
+}
+
+// globMatch matches s against pattern, where * matches any sequence of
+// characters
+func globMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	re, err := regexp.Compile("^" + strings.Join(parts, ".*") + "$")
+	if err != nil {
+		return false
+	}
+	return re.MatchString(s)
+}
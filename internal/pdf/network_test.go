@@ -0,0 +1,64 @@
+package pdf_test
+
+import (
+	"testing"
+
+	"github.com/sehrgutesoftware/httpdf/internal/pdf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkPolicy_Allows(t *testing.T) {
+	const origin = "http://[::]:41234"
+
+	t.Run("it_allows_requests_to_the_origin", func(t *testing.T) {
+		policy := pdf.NetworkPolicy{}
+
+		assert.True(t, policy.Allows(origin, "http://[::]:41234/assets/style.css"))
+		assert.False(t, policy.Allows(origin, "http://[::]:8080/"))
+	})
+
+	t.Run("it_allows_data_urls", func(t *testing.T) {
+		policy := pdf.NetworkPolicy{}
+
+		assert.True(t, policy.Allows(origin, "data:image/png;base64,iVBORw0KGgo="))
+	})
+
+	t.Run("it_allows_blob_urls_of_the_origin", func(t *testing.T) {
+		policy := pdf.NetworkPolicy{}
+
+		assert.True(t, policy.Allows(origin, "blob:http://[::]:41234/0e2c6bd7-8a0f-4b7c-9a4e-1c5d2f3a4b5c"))
+		assert.False(t, policy.Allows(origin, "blob:https://example.com/0e2c6bd7-8a0f-4b7c-9a4e-1c5d2f3a4b5c"))
+		assert.False(t, policy.Allows(origin, "blob:null/0e2c6bd7-8a0f-4b7c-9a4e-1c5d2f3a4b5c"))
+	})
+
+	t.Run("it_blocks_other_requests_by_default", func(t *testing.T) {
+		policy := pdf.NetworkPolicy{}
+
+		assert.False(t, policy.Allows(origin, "https://example.com/"))
+		assert.False(t, policy.Allows(origin, "http://169.254.169.254/latest/meta-data/"))
+		assert.False(t, policy.Allows(origin, "file:///etc/passwd"))
+	})
+
+	t.Run("it_allows_hosts_from_the_allowlist", func(t *testing.T) {
+		policy := pdf.NetworkPolicy{Allow: []string{"fonts.googleapis.com", "*.gstatic.com"}}
+
+		assert.True(t, policy.Allows(origin, "https://fonts.googleapis.com/css2?family=Inter"))
+		assert.True(t, policy.Allows(origin, "https://fonts.gstatic.com/s/inter.woff2"))
+		assert.False(t, policy.Allows(origin, "https://gstatic.com.evil.com/"))
+		assert.False(t, policy.Allows(origin, "https://googleapis.com/"))
+	})
+
+	t.Run("it_allows_urls_matching_a_pattern_from_the_allowlist", func(t *testing.T) {
+		policy := pdf.NetworkPolicy{Allow: []string{"https://cdn.example.com/charts/*"}}
+
+		assert.True(t, policy.Allows(origin, "https://cdn.example.com/charts/chart.js"))
+		assert.False(t, policy.Allows(origin, "http://cdn.example.com/charts/chart.js"))
+		assert.False(t, policy.Allows(origin, "https://cdn.example.com/other.js"))
+	})
+
+	t.Run("it_allows_all_requests_with_a_wildcard", func(t *testing.T) {
+		policy := pdf.NetworkPolicy{Allow: []string{"*"}}
+
+		assert.True(t, policy.Allows(origin, "https://example.com/"))
+	})
+}
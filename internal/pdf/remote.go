@@ -0,0 +1,155 @@
+package pdf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/cdp"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+const (
+	// connectAttempts is the number of attempts to connect to the remote
+	// browser before giving up
+	connectAttempts = 3
+	// connectBackoff is the delay before the second attempt; it grows
+	// linearly with each attempt
+	connectBackoff = 200 * time.Millisecond
+)
+
+// remoteRenderer is a Renderer implementation that connects to an already
+// running Chromium over the DevTools protocol, e.g. in a sidecar container
+type remoteRenderer struct {
+	endpoint string
+
+	mu      sync.Mutex
+	browser *rod.Browser
+	conn    *cdp.WebSocket
+}
+
+// NewRemoteRenderer creates a Renderer that connects to the Chromium instance
+// at endpoint. The endpoint is either the DevTools WebSocket URL, e.g.
+// ws://chromium:9222/devtools/browser/{id}, or the address of its HTTP
+// endpoint, e.g. http://chromium:9222, from which the WebSocket URL is
+// resolved.
+//
+// The connection is established on first use and shared between renders,
+// each of which runs in a separate browser context. If the connection is
+// lost, it is re-established on the next render.
+func NewRemoteRenderer(endpoint string) Renderer {
+	return &remoteRenderer{
+		endpoint: endpoint,
+	}
+}
+
+// Render renders a PDF from HTML content
+func (r *remoteRenderer) Render(ctx context.Context, url string, pdf io.Writer, opts RenderOpts) (Diagnostics, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	browser, err := r.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each render gets its own browser context, so cookies and storage
+	// aren't shared between renders
+	incognito, err := browser.Context(ctx).Incognito()
+	if err != nil {
+		// The connection may have been lost since the last render, so try
+		// once more with a new one
+		log.Printf("Reconnecting to browser: %v", err)
+		r.disconnect(browser)
+		if browser, err = r.connect(ctx); err != nil {
+			return nil, err
+		}
+		if incognito, err = browser.Context(ctx).Incognito(); err != nil {
+			r.disconnect(browser)
+			return nil, fmt.Errorf("failed to create browser context: %w", err)
+		}
+	}
+	defer incognito.Close()
+
+	page, err := incognito.Page(proto.TargetCreateTarget{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new page: %w", err)
+	}
+	defer page.Close()
+
+	return renderPage(page, url, pdf, opts)
+}
+
+// connect returns the shared connection to the browser, connecting first if
+// necessary
+func (r *remoteRenderer) connect(ctx context.Context) (*rod.Browser, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.browser != nil {
+		return r.browser, nil
+	}
+
+	var err error
+	for attempt := 1; attempt <= connectAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(time.Duration(attempt-1) * connectBackoff):
+			case <-ctx.Done():
+				return nil, fmt.Errorf("failed to connect to browser: %w", ctx.Err())
+			}
+		}
+
+		if err = r.dial(ctx); err == nil {
+			return r.browser, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to connect to browser after %d attempts: %w", connectAttempts, err)
+}
+
+// dial opens a new connection to the browser. The context only applies to
+// the handshake; the connection outlives the render it was opened for.
+func (r *remoteRenderer) dial(ctx context.Context) error {
+	controlURL := r.endpoint
+	if !strings.Contains(controlURL, "/devtools/browser/") {
+		var err error
+		if controlURL, err = launcher.ResolveURL(controlURL); err != nil {
+			return fmt.Errorf("resolve DevTools URL: %w", err)
+		}
+	}
+
+	conn := &cdp.WebSocket{}
+	if err := conn.Connect(ctx, controlURL, nil); err != nil {
+		return err
+	}
+	browser := rod.New().Client(cdp.New().Start(conn))
+	if err := browser.Connect(); err != nil {
+		conn.Close()
+		return err
+	}
+
+	r.browser = browser
+	r.conn = conn
+	return nil
+}
+
+// disconnect closes the connection, if it's still the shared one. The remote
+// browser itself keeps running.
+func (r *remoteRenderer) disconnect(browser *rod.Browser) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.browser == browser {
+		r.conn.Close()
+		r.browser = nil
+		r.conn = nil
+	}
+}
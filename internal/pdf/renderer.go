@@ -2,9 +2,14 @@ package pdf
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-rod/rod"
@@ -12,8 +17,100 @@ import (
 	"github.com/go-rod/rod/lib/proto"
 )
 
+var (
+	// ErrInvalidWait is returned for wait conditions not specifying exactly
+	// one condition
+	ErrInvalidWait = errors.New("invalid wait condition")
+)
+
+const (
+	// defaultWaitTimeout applies to wait conditions without a timeout
+	defaultWaitTimeout = 10 * time.Second
+	// networkIdleTime is the time without pending requests after which the
+	// network is considered idle
+	networkIdleTime = 500 * time.Millisecond
+)
+
+// Wait is a condition to wait for before printing the page. Exactly one of
+// Selector, Expression, Fonts, NetworkIdle or Delay must be set.
+type Wait struct {
+	// Selector waits for an element matching the CSS selector to exist
+	Selector string
+	// Expression waits for the JS expression to be truthy, e.g.
+	// `window.httpdfReady === true`
+	Expression string
+	// Fonts waits for document.fonts.ready
+	Fonts bool
+	// NetworkIdle waits until there have been no pending requests for 500ms
+	NetworkIdle bool
+	// Delay waits for a fixed amount of time, regardless of the timeout
+	Delay time.Duration
+	// Timeout is the maximum time to wait for the condition; defaults to 10s
+	Timeout time.Duration
+}
+
+// validate ensures that exactly one condition is set
+func (w Wait) validate() error {
+	n := 0
+	for _, set := range []bool{w.Selector != "", w.Expression != "", w.Fonts, w.NetworkIdle, w.Delay > 0} {
+		if set {
+			n++
+		}
+	}
+	if n != 1 {
+		return fmt.Errorf("%w: expected exactly one condition, got %d", ErrInvalidWait, n)
+	}
+	if w.Timeout < 0 {
+		return fmt.Errorf("%w: negative timeout", ErrInvalidWait)
+	}
+	return nil
+}
+
+// timeout returns the timeout of the condition, or the default timeout
+func (w Wait) timeout() time.Duration {
+	if w.Timeout > 0 {
+		return w.Timeout
+	}
+	return defaultWaitTimeout
+}
+
+// String describes the condition for error messages
+func (w Wait) String() string {
+	switch {
+	case w.Selector != "":
+		return fmt.Sprintf("selector %q", w.Selector)
+	case w.Expression != "":
+		return fmt.Sprintf("expression %q", w.Expression)
+	case w.Fonts:
+		return "fonts"
+	case w.NetworkIdle:
+		return "network idle"
+	default:
+		return fmt.Sprintf("delay of %s", w.Delay)
+	}
+}
+
+// Format is the output format of a render
+type Format int
+
+const (
+	// FormatPDF prints the page as PDF
+	FormatPDF Format = iota
+	// FormatPNG takes a screenshot of the whole page as PNG, with a viewport
+	// as wide as the page and print media emulated. It's meant for visual
+	// comparisons of the layout, e.g. in regression tests.
+	FormatPNG
+)
+
+// pixels converts mm to CSS pixels
+func pixels(mm float64) int {
+	return int(math.Round(mm / 25.4 * 96))
+}
+
 // RenderOpts contains options for rendering a PDF
 type RenderOpts struct {
+	// Format is the output format, PDF by default
+	Format Format
 	// The width of the PDF page in mm
 	Width float64
 	// The height of the PDF page in mm
@@ -24,11 +121,31 @@ type RenderOpts struct {
 	// GenerateDocumentOutline indicates whether to generate a document outline,
 	// see https://chromedevtools.github.io/devtools-protocol/tot/Page/#method-printToPDF
 	GenerateDocumentOutline bool
+	// Wait lists conditions to wait for, in order, before printing the page.
+	// If empty, the page is printed as soon as it is stable.
+	Wait []Wait
+	// Network restricts the URLs the page may request
+	Network NetworkPolicy
+	// Strict fails the render if the page throws an exception or fails to
+	// load a resource
+	Strict bool
+}
+
+// validate checks the options before starting to render
+func (opts RenderOpts) validate() error {
+	for i, w := range opts.Wait {
+		if err := w.validate(); err != nil {
+			return fmt.Errorf("wait %d: %w", i+1, err)
+		}
+	}
+	return nil
 }
 
 // Renderer is an interface for rendering PDFs from HTML content
 type Renderer interface {
-	Render(ctx context.Context, url string, pdf io.Writer, opts RenderOpts) error
+	// Render prints the page at url as PDF. It returns the diagnostics
+	// reported by the page, even if rendering failed.
+	Render(ctx context.Context, url string, pdf io.Writer, opts RenderOpts) (Diagnostics, error)
 }
 
 // rodRenderer is a Renderer implementation that uses rod to render PDFs
@@ -44,7 +161,11 @@ func NewRodRenderer(chromium string) Renderer {
 }
 
 // Render renders a PDF from HTML content
-func (r *rodRenderer) Render(ctx context.Context, url string, pdf io.Writer, opts RenderOpts) error {
+func (r *rodRenderer) Render(ctx context.Context, url string, pdf io.Writer, opts RenderOpts) (Diagnostics, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
 	// Launch a new browser with default options
 	l, err := launcher.New().
 		Context(ctx).
@@ -59,7 +180,7 @@ func (r *rodRenderer) Render(ctx context.Context, url string, pdf io.Writer, opt
 		Set("no-zygote").
 		Launch()
 	if err != nil {
-		return fmt.Errorf("failed to launch browser: %w", err)
+		return nil, fmt.Errorf("failed to launch browser: %w", err)
 	}
 
 	// Connect to the browser
@@ -67,19 +188,79 @@ func (r *rodRenderer) Render(ctx context.Context, url string, pdf io.Writer, opt
 	defer browser.Close()
 	err = browser.Connect()
 	if err != nil {
-		return fmt.Errorf("failed to connect to browser: %w", err)
+		return nil, fmt.Errorf("failed to connect to browser: %w", err)
 	}
 
 	// Load the HTML content into a new page
-	page, err := browser.Page(proto.TargetCreateTarget{
-		URL: url,
+	page, err := browser.Page(proto.TargetCreateTarget{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new page: %w", err)
+	}
+
+	return renderPage(page, url, pdf, opts)
+}
+
+// renderPage loads url into the blank page, enforcing the network policy,
+// and prints it as PDF
+func renderPage(page *rod.Page, url string, pdf io.Writer, opts RenderOpts) (Diagnostics, error) {
+	router := page.HijackRequests()
+	err := router.Add("*", "", func(h *rod.Hijack) {
+		requested := h.Request.URL().String()
+		if !opts.Network.Allows(url, requested) {
+			log.Printf("Blocked request to %s", requested)
+			h.Response.Fail(proto.NetworkErrorReasonBlockedByClient)
+			return
+		}
+		h.ContinueRequest(&proto.FetchContinueRequest{})
 	})
 	if err != nil {
-		return fmt.Errorf("failed to create new page: %w", err)
+		return nil, fmt.Errorf("failed to intercept requests: %w", err)
 	}
-	err = page.WaitStable(50 * time.Millisecond)
+	go router.Run()
+	defer router.Stop()
+
+	if opts.Format == FormatPNG {
+		err := proto.EmulationSetDeviceMetricsOverride{
+			Width:             pixels(opts.Width),
+			Height:            pixels(opts.Height),
+			DeviceScaleFactor: 1,
+		}.Call(page)
+		if err == nil {
+			err = proto.EmulationSetEmulatedMedia{Media: "print"}.Call(page)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up viewport: %w", err)
+		}
+	}
+
+	stop := collect(page)
+	sockets := watchWebSockets(page, url, opts.Network)
+	err = load(page, url, opts.Wait)
+	blocked := sockets()
+	diagnostics := stop()
 	if err != nil {
-		return fmt.Errorf("failed to wait for page load: %w", err)
+		return diagnostics, err
+	}
+	if len(blocked) > 0 {
+		return diagnostics, fmt.Errorf("%w: %s", ErrBlockedWebSocket, strings.Join(blocked, ", "))
+	}
+	if opts.Strict {
+		if err := diagnostics.Err(); err != nil {
+			return diagnostics, err
+		}
+	}
+
+	if opts.Format == FormatPNG {
+		screenshot, err := page.Screenshot(true, &proto.PageCaptureScreenshot{
+			Format: proto.PageCaptureScreenshotFormatPng,
+		})
+		if err != nil {
+			return diagnostics, fmt.Errorf("failed to take screenshot: %w", err)
+		}
+		if _, err := pdf.Write(screenshot); err != nil {
+			return diagnostics, fmt.Errorf("failed to write screenshot to output stream: %w", err)
+		}
+		return diagnostics, nil
 	}
 
 	// Save the page as a PDF
@@ -100,14 +281,113 @@ func (r *rodRenderer) Render(ctx context.Context, url string, pdf io.Writer, opt
 		GenerateDocumentOutline: opts.GenerateDocumentOutline,
 	})
 	if err != nil {
-		return fmt.Errorf("failed generate PDF from HTML: %w", err)
+		return diagnostics, fmt.Errorf("failed generate PDF from HTML: %w", err)
 	}
 
 	// Write the PDF to the output stream
 	_, err = io.Copy(pdf, pdfStream)
 	pdfStream.Close()
 	if err != nil {
-		return fmt.Errorf("failed to write PDF to output stream: %w", err)
+		return diagnostics, fmt.Errorf("failed to write PDF to output stream: %w", err)
+	}
+
+	return diagnostics, nil
+}
+
+// watchWebSockets records the WebSocket connections the page opens outside
+// the network policy, which request interception doesn't see. The returned
+// function stops recording and returns their URLs.
+func watchWebSockets(page *rod.Page, origin string, policy NetworkPolicy) func() []string {
+	var (
+		mu      sync.Mutex
+		blocked []string
+	)
+	p, cancel := page.WithCancel()
+	wait := p.EachEvent(func(e *proto.NetworkWebSocketCreated) {
+		if policy.Allows(origin, e.URL) {
+			return
+		}
+		log.Printf("Blocked WebSocket connection to %s", e.URL)
+		mu.Lock()
+		blocked = append(blocked, e.URL)
+		mu.Unlock()
+	})
+	done := make(chan struct{})
+	go func() {
+		wait()
+		close(done)
+	}()
+
+	return func() []string {
+		cancel()
+		<-done
+		mu.Lock()
+		defer mu.Unlock()
+		return blocked
+	}
+}
+
+// load navigates the page to url and waits for the conditions. Network idle
+// conditions are registered before navigating, so their timeout includes
+// loading the page.
+func load(page *rod.Page, url string, conditions []Wait) error {
+	if len(conditions) == 0 {
+		if err := page.Navigate(url); err != nil {
+			return fmt.Errorf("failed to load page: %w", err)
+		}
+		if err := page.WaitStable(50 * time.Millisecond); err != nil {
+			return fmt.Errorf("failed to wait for page load: %w", err)
+		}
+		return nil
+	}
+
+	idle := make(map[int]func() error)
+	for i, w := range conditions {
+		if w.NetworkIdle {
+			p := page.Timeout(w.timeout())
+			wait := p.WaitRequestIdle(networkIdleTime, nil, nil, []proto.NetworkResourceType{
+				proto.NetworkResourceTypeWebSocket,
+				proto.NetworkResourceTypeEventSource,
+				proto.NetworkResourceTypeMedia,
+			})
+			idle[i] = func() error {
+				defer p.CancelTimeout()
+				wait()
+				return p.GetContext().Err()
+			}
+		}
+	}
+
+	if err := page.Navigate(url); err != nil {
+		return fmt.Errorf("failed to load page: %w", err)
+	}
+	if err := page.WaitLoad(); err != nil {
+		return fmt.Errorf("failed to wait for page load: %w", err)
+	}
+
+	for i, w := range conditions {
+		p := page.Timeout(w.timeout())
+		var err error
+		switch {
+		case w.Selector != "":
+			_, err = p.Element(w.Selector)
+		case w.Expression != "":
+			err = p.Wait(rod.Eval(`() => (` + w.Expression + `)`))
+		case w.Fonts:
+			_, err = p.Evaluate(rod.Eval(`() => document.fonts.ready.then(() => true)`).ByPromise())
+		case w.NetworkIdle:
+			err = idle[i]()
+		case w.Delay > 0:
+			select {
+			case <-time.After(w.Delay):
+			case <-page.GetContext().Done():
+				err = page.GetContext().Err()
+			}
+		}
+		p.CancelTimeout()
+		if err != nil {
+			return fmt.Errorf("failed to wait for %s: %w", w, err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,60 @@
+package pdf_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sehrgutesoftware/httpdf/internal/pdf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRodRenderer_Render(t *testing.T) {
+	t.Run("it_rejects_wait_conditions_without_a_condition", func(t *testing.T) {
+		r := pdf.NewRodRenderer("/nonexistent/chromium")
+
+		_, err := r.Render(context.Background(), "about:blank", &bytes.Buffer{}, pdf.RenderOpts{
+			Wait: []pdf.Wait{{Timeout: time.Second}},
+		})
+
+		assert.ErrorIs(t, err, pdf.ErrInvalidWait)
+	})
+
+	t.Run("it_rejects_wait_conditions_with_multiple_conditions", func(t *testing.T) {
+		r := pdf.NewRodRenderer("/nonexistent/chromium")
+
+		_, err := r.Render(context.Background(), "about:blank", &bytes.Buffer{}, pdf.RenderOpts{
+			Wait: []pdf.Wait{{Fonts: true}, {Selector: "#chart", NetworkIdle: true}},
+		})
+
+		assert.ErrorIs(t, err, pdf.ErrInvalidWait)
+		assert.Contains(t, err.Error(), "wait 2")
+	})
+}
+
+func TestRemoteRenderer_Render(t *testing.T) {
+	t.Run("it_returns_an_error_if_the_browser_is_unreachable", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		endpoint := "http://" + listener.Addr().String()
+		listener.Close()
+		r := pdf.NewRemoteRenderer(endpoint)
+
+		_, err = r.Render(context.Background(), "about:blank", &bytes.Buffer{}, pdf.RenderOpts{})
+
+		assert.ErrorContains(t, err, "failed to connect to browser")
+	})
+
+	t.Run("it_rejects_invalid_wait_conditions_before_connecting", func(t *testing.T) {
+		r := pdf.NewRemoteRenderer("ws://127.0.0.1:1/devtools/browser/none")
+
+		_, err := r.Render(context.Background(), "about:blank", &bytes.Buffer{}, pdf.RenderOpts{
+			Wait: []pdf.Wait{{}},
+		})
+
+		assert.ErrorIs(t, err, pdf.ErrInvalidWait)
+	})
+}
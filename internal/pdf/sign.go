@@ -0,0 +1,438 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"golang.org/x/text/encoding/charmap"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+var (
+	// ErrEncryptedDocument is returned when trying to sign an encrypted PDF
+	ErrEncryptedDocument = errors.New("signing encrypted documents is not supported")
+
+	startxrefPattern = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`)
+)
+
+func init() {
+	// Prevent pdfcpu from creating a configuration directory in the user's
+	// home directory. The built-in defaults are sufficient for our use.
+	api.DisableConfigDir()
+}
+
+// Credentials hold the certificate and private key used for signing
+type Credentials struct {
+	// Certificate is the signer's certificate
+	Certificate *x509.Certificate
+	// Chain contains the intermediate certificates, if any
+	Chain []*x509.Certificate
+	// Key is the private key belonging to the certificate
+	Key crypto.Signer
+}
+
+// LoadPKCS12 reads signing credentials from a PKCS#12 (.p12/.pfx) file
+func LoadPKCS12(path, password string) (*Credentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read PKCS#12 file: %w", err)
+	}
+
+	key, cert, chain, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("decode PKCS#12 file: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("decode PKCS#12 file: private key cannot be used for signing")
+	}
+
+	return &Credentials{Certificate: cert, Chain: chain, Key: signer}, nil
+}
+
+// LoadPEM reads signing credentials from PEM encoded certificate and key files.
+// The certificate file may contain intermediate certificates following the
+// signer's certificate.
+func LoadPEM(certPath, keyPath string) (*Credentials, error) {
+	certData, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("read certificate file: %w", err)
+	}
+	keyData, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(certData); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("parse certificate: no certificate found")
+	}
+
+	block, _ := pem.Decode(keyData)
+	if block == nil {
+		return nil, errors.New("parse key: no PEM data found")
+	}
+	key, err := parsePrivateKey(block)
+	if err != nil {
+		return nil, fmt.Errorf("parse key: %w", err)
+	}
+
+	return &Credentials{Certificate: certs[0], Chain: certs[1:], Key: key}, nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	var (
+		key any
+		err error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key cannot be used for signing")
+	}
+	return signer, nil
+}
+
+// SignOpts contains options for signing a PDF
+type SignOpts struct {
+	// Name of the signer; defaults to the certificate's common name
+	Name string
+	// Reason for signing, e.g. "Contract approval"
+	Reason string
+	// Location where the document was signed
+	Location string
+	// ContactInfo of the signer
+	ContactInfo string
+	// Appearance places a visible signature on a page. If nil, the signature
+	// is invisible.
+	Appearance *SignatureAppearance
+}
+
+// SignatureAppearance describes the placement of a visible signature. All
+// distances are in mm, measured from the top left corner of the page.
+type SignatureAppearance struct {
+	// Page is the 1-based page number; 0 means the last page
+	Page   int
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+// Sign applies a PAdES compatible (ETSI.CAdES.detached) digital signature to
+// the PDF document and writes the signed document to out. The signature is
+// appended as an incremental update, leaving the original bytes untouched.
+func Sign(document []byte, out io.Writer, creds *Credentials, opts SignOpts) error {
+	ctx, err := api.ReadContext(bytes.NewReader(document), model.NewDefaultConfiguration())
+	if err != nil {
+		return fmt.Errorf("read PDF: %w", err)
+	}
+	if ctx.Encrypt != nil {
+		return ErrEncryptedDocument
+	}
+	if err := ctx.EnsurePageCount(); err != nil {
+		return fmt.Errorf("read PDF: %w", err)
+	}
+
+	m := startxrefPattern.FindSubmatch(document)
+	if m == nil {
+		return errors.New("read PDF: startxref not found")
+	}
+	prevXRef, _ := strconv.ParseInt(string(m[1]), 10, 64)
+
+	inc := &increment{
+		buf:     bytes.NewBuffer(append([]byte(nil), document...)),
+		nextObj: *ctx.Size,
+		offsets: make(map[int]int),
+	}
+	if document[len(document)-1] != '\n' {
+		inc.buf.WriteByte('\n')
+	}
+
+	signer := opts.Name
+	if signer == "" {
+		signer = creds.Certificate.Subject.CommonName
+	}
+	now := time.Now()
+
+	// Signature dictionary, including placeholders for the byte range and
+	// the signature contents, which are filled in once the layout is final.
+	reserved := 8192
+	for _, cert := range append([]*x509.Certificate{creds.Certificate}, creds.Chain...) {
+		reserved += len(cert.Raw)
+	}
+	sigObj := inc.reserve()
+	sigDict := fmt.Sprintf("<< /Type /Sig /Filter /Adobe.PPKLite /SubFilter /ETSI.CAdES.detached "+
+		"/ByteRange %s /Contents <%s> /M %s /Name %s",
+		byteRangePlaceholder, strings.Repeat("0", reserved*2), pdfString(pdfDate(now)), pdfString(signer))
+	for _, entry := range [][2]string{{"Reason", opts.Reason}, {"Location", opts.Location}, {"ContactInfo", opts.ContactInfo}} {
+		if entry[1] != "" {
+			sigDict += fmt.Sprintf(" /%s %s", entry[0], pdfString(entry[1]))
+		}
+	}
+	inc.object(sigObj, sigDict+" >>")
+
+	// Signature field, merged with its widget annotation
+	pageNr := ctx.PageCount
+	rect := "[0 0 0 0]"
+	appearance := ""
+	if opts.Appearance != nil {
+		if opts.Appearance.Page > 0 {
+			pageNr = opts.Appearance.Page
+		}
+		if pageNr > ctx.PageCount {
+			return fmt.Errorf("signature appearance: page %d does not exist", pageNr)
+		}
+	}
+	pageDict, pageRef, pageAttrs, err := ctx.PageDict(pageNr, false)
+	if err != nil {
+		return fmt.Errorf("read page %d: %w", pageNr, err)
+	}
+	if opts.Appearance != nil {
+		box := pageAttrs.MediaBox
+		x := box.LL.X + toPoints(opts.Appearance.X)
+		y := box.UR.Y - toPoints(opts.Appearance.Y) - toPoints(opts.Appearance.Height)
+		w := toPoints(opts.Appearance.Width)
+		h := toPoints(opts.Appearance.Height)
+		rect = fmt.Sprintf("[%.2f %.2f %.2f %.2f]", x, y, x+w, y+h)
+
+		apObj := inc.reserve()
+		stream := appearanceStream(w, h, []string{
+			"Digitally signed by " + signer,
+			"Date: " + now.Format("2006-01-02 15:04:05 -07:00"),
+			opts.Reason,
+			opts.Location,
+		})
+		inc.object(apObj, fmt.Sprintf("<< /Type /XObject /Subtype /Form /BBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << /Helv << /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >> >> >> "+
+			"/Length %d >>\nstream\n%s\nendstream", w, h, len(stream), stream))
+		appearance = fmt.Sprintf(" /AP << /N %d 0 R >>", apObj)
+	}
+	fieldObj := inc.reserve()
+	inc.object(fieldObj, fmt.Sprintf("<< /Type /Annot /Subtype /Widget /FT /Sig /F 132 /T %s /V %d 0 R /P %s /Rect %s%s >>",
+		pdfString(fmt.Sprintf("Signature%d", fieldObj)), sigObj, pageRef.PDFString(), rect, appearance))
+
+	// Add the widget to the page's annotations
+	annots := types.Array{}
+	if existing, found := pageDict.Find("Annots"); found {
+		if annots, err = ctx.DereferenceArray(existing); err != nil {
+			return fmt.Errorf("read page annotations: %w", err)
+		}
+	}
+	pageDict.Update("Annots", append(annots, *types.NewIndirectRef(fieldObj, 0)))
+	inc.object(pageRef.ObjectNumber.Value(), pageDict.PDFString())
+
+	// Register the field with the document's interactive form
+	catalog, err := ctx.Catalog()
+	if err != nil {
+		return fmt.Errorf("read catalog: %w", err)
+	}
+	acroForm := types.Dict{}
+	if existing, found := catalog.Find("AcroForm"); found {
+		if acroForm, err = ctx.DereferenceDict(existing); err != nil {
+			return fmt.Errorf("read form: %w", err)
+		}
+	}
+	fields := types.Array{}
+	if existing, found := acroForm.Find("Fields"); found {
+		if fields, err = ctx.DereferenceArray(existing); err != nil {
+			return fmt.Errorf("read form fields: %w", err)
+		}
+	}
+	acroForm.Update("Fields", append(fields, *types.NewIndirectRef(fieldObj, 0)))
+	acroForm.Update("SigFlags", types.Integer(3))
+	formObj := inc.reserve()
+	inc.object(formObj, acroForm.PDFString())
+	catalog.Update("AcroForm", *types.NewIndirectRef(formObj, 0))
+	inc.object(ctx.Root.ObjectNumber.Value(), catalog.PDFString())
+
+	trailer := types.Dict{"Root": *ctx.Root}
+	if ctx.Info != nil {
+		trailer.Insert("Info", *ctx.Info)
+	}
+	if len(ctx.XRefTable.ID) > 0 {
+		trailer.Insert("ID", ctx.XRefTable.ID)
+	}
+	inc.finish(trailer, prevXRef, ctx.Read.UsingXRefStreams)
+
+	// Fill in the byte range and compute the signature over everything but
+	// the signature contents.
+	signed := inc.buf.Bytes()
+	contentsStart := bytes.Index(signed[len(document):], []byte("/Contents <")) + len(document) + len("/Contents ")
+	contentsEnd := contentsStart + reserved*2 + 2
+	byteRange := fmt.Sprintf("[0 %d %d %d]", contentsStart, contentsEnd, len(signed)-contentsEnd)
+	rangeStart := bytes.Index(signed[len(document):], []byte(byteRangePlaceholder)) + len(document)
+	copy(signed[rangeStart:], byteRange+strings.Repeat(" ", len(byteRangePlaceholder)-len(byteRange)))
+
+	h := sha256.New()
+	h.Write(signed[:contentsStart])
+	h.Write(signed[contentsEnd:])
+	signature, err := cmsSignDetached(h.Sum(nil), creds)
+	if err != nil {
+		return fmt.Errorf("create signature: %w", err)
+	}
+	if len(signature) > reserved {
+		return errors.New("create signature: signature exceeds reserved space")
+	}
+	hex.Encode(signed[contentsStart+1:], signature)
+
+	if _, err := out.Write(signed); err != nil {
+		return fmt.Errorf("failed to write PDF to output stream: %w", err)
+	}
+
+	return nil
+}
+
+const byteRangePlaceholder = "[0 0000000000 0000000000 0000000000]"
+
+// increment collects the objects of an incremental update to a PDF document
+type increment struct {
+	buf     *bytes.Buffer
+	nextObj int
+	offsets map[int]int
+}
+
+// reserve returns a new object number
+func (inc *increment) reserve() int {
+	inc.nextObj++
+	return inc.nextObj - 1
+}
+
+// object appends an object with the given number and content
+func (inc *increment) object(nr int, content string) {
+	inc.offsets[nr] = inc.buf.Len()
+	fmt.Fprintf(inc.buf, "%d 0 obj\n%s\nendobj\n", nr, content)
+}
+
+// finish writes the cross-reference section and trailer for the increment,
+// using an xref stream if the original document does so.
+func (inc *increment) finish(trailer types.Dict, prevXRef int64, xrefStream bool) {
+	trailer.Insert("Prev", types.Integer(int(prevXRef)))
+
+	if !xrefStream {
+		trailer.Insert("Size", types.Integer(inc.nextObj))
+		xrefOffset := inc.buf.Len()
+		inc.buf.WriteString("xref\n0 1\n0000000000 65535 f \n")
+		for _, nr := range inc.objectNumbers() {
+			fmt.Fprintf(inc.buf, "%d 1\n%010d 00000 n \n", nr, inc.offsets[nr])
+		}
+		fmt.Fprintf(inc.buf, "trailer\n%s\nstartxref\n%d\n%%%%EOF\n", trailer.PDFString(), xrefOffset)
+		return
+	}
+
+	xrefObj := inc.reserve()
+	inc.offsets[xrefObj] = inc.buf.Len()
+	index := types.Array{}
+	var data []byte
+	for _, nr := range inc.objectNumbers() {
+		offset := inc.offsets[nr]
+		index = append(index, types.Integer(nr), types.Integer(1))
+		data = append(data, 1, byte(offset>>24), byte(offset>>16), byte(offset>>8), byte(offset), 0)
+	}
+	trailer.Insert("Type", types.Name("XRef"))
+	trailer.Insert("Size", types.Integer(inc.nextObj))
+	trailer.Insert("Index", index)
+	trailer.Insert("W", types.Array{types.Integer(1), types.Integer(4), types.Integer(1)})
+	trailer.Insert("Length", types.Integer(len(data)))
+	fmt.Fprintf(inc.buf, "%d 0 obj\n%s\nstream\n", xrefObj, trailer.PDFString())
+	inc.buf.Write(data)
+	fmt.Fprintf(inc.buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", inc.offsets[xrefObj])
+}
+
+func (inc *increment) objectNumbers() []int {
+	nrs := make([]int, 0, len(inc.offsets))
+	for nr := range inc.offsets {
+		nrs = append(nrs, nr)
+	}
+	sort.Ints(nrs)
+	return nrs
+}
+
+// appearanceStream draws a framed box with the given lines of text
+func appearanceStream(w, h float64, lines []string) string {
+	var text []string
+	for _, line := range lines {
+		if line != "" {
+			text = append(text, line)
+		}
+	}
+
+	fontSize := min(10, h/(float64(len(text))*1.4+0.6))
+	var s strings.Builder
+	fmt.Fprintf(&s, "q 0.5 w 0.2 G 0.25 0.25 %.2f %.2f re S Q\n", w-0.5, h-0.5)
+	fmt.Fprintf(&s, "BT /Helv %.2f Tf 0 g %.2f TL %.2f %.2f Td\n", fontSize, fontSize*1.4, fontSize*0.5, h-fontSize*1.3)
+	for _, line := range text {
+		fmt.Fprintf(&s, "%s Tj T*\n", pdfString(line))
+	}
+	s.WriteString("ET")
+
+	return s.String()
+}
+
+// pdfString encodes s as a PDF literal string in WinAnsi encoding
+func pdfString(s string) string {
+	encoded, err := charmap.Windows1252.NewEncoder().String(s)
+	if err != nil {
+		encoded = strings.Map(func(r rune) rune {
+			if r > 0x7e {
+				return '?'
+			}
+			return r
+		}, s)
+	}
+
+	r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", `\r`, "\n", `\n`)
+	return "(" + r.Replace(encoded) + ")"
+}
+
+// pdfDate formats t as a PDF date string
+func pdfDate(t time.Time) string {
+	_, offset := t.Zone()
+	sign := "+"
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	return fmt.Sprintf("D:%s%s%02d'%02d'", t.Format("20060102150405"), sign, offset/3600, offset%3600/60)
+}
+
+// toPoints converts mm to PDF points
+func toPoints(mm float64) float64 {
+	return mm / 25.4 * 72
+}
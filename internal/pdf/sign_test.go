@@ -0,0 +1,219 @@
+package pdf_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/sehrgutesoftware/httpdf/internal/pdf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestSign(t *testing.T) {
+	creds := selfSignedCredentials(t)
+
+	t.Run("it_signs_a_document_invisibly", func(t *testing.T) {
+		document := testDocument(2)
+
+		var out bytes.Buffer
+		err := pdf.Sign(document, &out, creds, pdf.SignOpts{Reason: "Test"})
+
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(out.Bytes(), document), "original document must be left untouched")
+		assert.Contains(t, out.String(), "/SubFilter /ETSI.CAdES.detached")
+		assertValidSignature(t, out.Bytes())
+	})
+
+	t.Run("it_signs_a_document_with_a_visible_appearance", func(t *testing.T) {
+		var out bytes.Buffer
+		err := pdf.Sign(testDocument(1), &out, creds, pdf.SignOpts{
+			Reason:     "Contract approval",
+			Location:   "Berlin",
+			Appearance: &pdf.SignatureAppearance{Page: 1, X: 20, Y: 250, Width: 60, Height: 20},
+		})
+
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), "/AP << /N ")
+		assert.Contains(t, out.String(), "(Digitally signed by httpdf test) Tj")
+		assertValidSignature(t, out.Bytes())
+	})
+
+	t.Run("it_signs_an_already_signed_document_again", func(t *testing.T) {
+		var first, second bytes.Buffer
+		require.NoError(t, pdf.Sign(testDocument(1), &first, creds, pdf.SignOpts{}))
+
+		err := pdf.Sign(first.Bytes(), &second, creds, pdf.SignOpts{})
+
+		require.NoError(t, err)
+		assertValidSignature(t, second.Bytes())
+	})
+
+	t.Run("it_signs_a_document_using_xref_streams", func(t *testing.T) {
+		var document, out bytes.Buffer
+		require.NoError(t, api.Optimize(bytes.NewReader(testDocument(1)), &document, nil))
+		require.Contains(t, document.String(), "/XRef")
+
+		err := pdf.Sign(document.Bytes(), &out, creds, pdf.SignOpts{})
+
+		require.NoError(t, err)
+		assertValidSignature(t, out.Bytes())
+	})
+
+	t.Run("it_returns_an_error_if_the_appearance_page_does_not_exist", func(t *testing.T) {
+		err := pdf.Sign(testDocument(1), &bytes.Buffer{}, creds, pdf.SignOpts{
+			Appearance: &pdf.SignatureAppearance{Page: 3, Width: 10, Height: 10},
+		})
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "page 3 does not exist")
+	})
+
+	t.Run("it_returns_an_error_if_the_input_is_not_a_pdf", func(t *testing.T) {
+		err := pdf.Sign([]byte("not a pdf"), &bytes.Buffer{}, creds, pdf.SignOpts{})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestLoadCredentials(t *testing.T) {
+	creds := selfSignedCredentials(t)
+	dir := t.TempDir()
+
+	t.Run("it_loads_credentials_from_pem_files", func(t *testing.T) {
+		key, err := x509.MarshalPKCS8PrivateKey(creds.Key)
+		require.NoError(t, err)
+		certPath := filepath.Join(dir, "cert.pem")
+		keyPath := filepath.Join(dir, "key.pem")
+		require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: creds.Certificate.Raw}), 0o600))
+		require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600))
+
+		loaded, err := pdf.LoadPEM(certPath, keyPath)
+
+		require.NoError(t, err)
+		assert.Equal(t, creds.Certificate.Raw, loaded.Certificate.Raw)
+		assert.Empty(t, loaded.Chain)
+	})
+
+	t.Run("it_loads_credentials_from_a_pkcs12_file", func(t *testing.T) {
+		p12, err := pkcs12.Modern.Encode(creds.Key, creds.Certificate, nil, "secret")
+		require.NoError(t, err)
+		p12Path := filepath.Join(dir, "cert.p12")
+		require.NoError(t, os.WriteFile(p12Path, p12, 0o600))
+
+		loaded, err := pdf.LoadPKCS12(p12Path, "secret")
+
+		require.NoError(t, err)
+		assert.Equal(t, creds.Certificate.Raw, loaded.Certificate.Raw)
+	})
+
+	t.Run("it_returns_an_error_for_a_wrong_pkcs12_password", func(t *testing.T) {
+		p12, err := pkcs12.Modern.Encode(creds.Key, creds.Certificate, nil, "secret")
+		require.NoError(t, err)
+		p12Path := filepath.Join(dir, "wrong.p12")
+		require.NoError(t, os.WriteFile(p12Path, p12, 0o600))
+
+		_, err = pdf.LoadPKCS12(p12Path, "wrong")
+
+		assert.Error(t, err)
+	})
+
+	t.Run("it_returns_an_error_for_missing_files", func(t *testing.T) {
+		_, err := pdf.LoadPEM(filepath.Join(dir, "missing.pem"), filepath.Join(dir, "missing.key"))
+
+		assert.Error(t, err)
+	})
+}
+
+func assertValidSignature(t *testing.T, signed []byte) {
+	t.Helper()
+
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.VALIDATESIGNATURE
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(signed), conf)
+	require.NoError(t, err)
+
+	results, err := pdfcpu.ValidateSignatures(bytes.NewReader(signed), ctx, true)
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	for _, result := range results {
+		// The self-signed test certificate is not trusted, but the signature
+		// itself must be intact and cover the whole document.
+		assert.True(t, result.Signed, "expected document to be signed")
+		assert.Equal(t, "ETSI.CAdES.detached", result.Details.SubFilter)
+		assert.Equal(t, "httpdf test", result.Details.SignerName)
+		assert.Equal(t, model.False, result.DocModified)
+		assert.NotEqual(t, model.SignatureReasonSignatureForged, result.Reason)
+	}
+}
+
+// selfSignedCredentials creates a self-signed certificate for testing
+func selfSignedCredentials(t *testing.T) *pdf.Credentials {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "httpdf test", Organization: []string{"httpdf"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &pdf.Credentials{Certificate: cert, Key: key}
+}
+
+// testDocument builds a minimal, valid PDF document with the given number of
+// A4 pages
+func testDocument(pages int) []byte {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(content string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), content)
+	}
+
+	buf.WriteString("%PDF-1.7\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	kids := ""
+	for i := range pages {
+		kids += fmt.Sprintf("%d 0 R ", 4+i*2)
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 595.28 841.89] >>", kids, pages))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+	for i := range pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", 5+i*2))
+		content := fmt.Sprintf("BT /F1 24 Tf 72 720 Td (Page %d) Tj ET", i+1)
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,114 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Watermark describes a text or image overlay applied to the pages of a PDF
+type Watermark struct {
+	// Text to print; ignored if Image is set
+	Text string
+	// Image contains an encoded PNG, JPEG, TIFF or WebP image
+	Image []byte
+	// OnTop places the watermark on top of the page content (a stamp)
+	// instead of behind it
+	OnTop bool
+	// Rotation in degrees, counterclockwise. If nil, the watermark is placed
+	// diagonally across the page.
+	Rotation *float64
+	// Opacity between 0 and 1; defaults to 1
+	Opacity float64
+	// Position is the anchor on the page: tl, tc, tr, l, c, r, bl, bc, br;
+	// defaults to c
+	Position string
+	// OffsetX and OffsetY move the watermark relative to its anchor, in mm
+	OffsetX float64
+	OffsetY float64
+	// Scale relative to the page size, between 0 and 1; defaults to 0.5
+	Scale float64
+	// FontSize in points, for text watermarks
+	FontSize int
+	// Color of the text as hex value, e.g. #808080
+	Color string
+	// Pages selects the pages to apply the watermark to, e.g. "1", "2-4",
+	// "odd", "even" or "!1" to exclude the first page. Empty means all pages.
+	Pages []string
+}
+
+// description renders the watermark as pdfcpu watermark description
+func (wm Watermark) description() string {
+	var desc []string
+	if wm.Rotation != nil {
+		desc = append(desc, fmt.Sprintf("rotation:%g", *wm.Rotation))
+	}
+	if wm.Opacity > 0 {
+		desc = append(desc, fmt.Sprintf("opacity:%g", wm.Opacity))
+	}
+	if wm.Position != "" {
+		desc = append(desc, "position:"+wm.Position)
+	}
+	if wm.OffsetX != 0 || wm.OffsetY != 0 {
+		desc = append(desc, fmt.Sprintf("offset:%g %g", wm.OffsetX, wm.OffsetY))
+	}
+	if wm.Scale > 0 {
+		desc = append(desc, fmt.Sprintf("scalefactor:%g rel", wm.Scale))
+	}
+	if wm.FontSize > 0 {
+		desc = append(desc, fmt.Sprintf("points:%d", wm.FontSize))
+	}
+	if wm.Color != "" {
+		desc = append(desc, "fillcolor:"+wm.Color)
+	}
+
+	return strings.Join(desc, ", ")
+}
+
+// AddWatermarks applies the watermarks to the PDF document and writes the
+// result to out
+func AddWatermarks(document []byte, out io.Writer, watermarks []Watermark) error {
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.ADDWATERMARKS
+	conf.OptimizeDuplicateContentStreams = false
+
+	ctx, err := api.ReadValidateAndOptimize(bytes.NewReader(document), conf)
+	if err != nil {
+		return fmt.Errorf("read PDF: %w", err)
+	}
+
+	for i, wm := range watermarks {
+		var w *model.Watermark
+		switch {
+		case len(wm.Image) > 0:
+			w, err = api.ImageWatermarkForReader(bytes.NewReader(wm.Image), wm.description(), wm.OnTop, false, types.MILLIMETRES)
+		case wm.Text != "":
+			w, err = api.TextWatermark(wm.Text, wm.description(), wm.OnTop, false, types.MILLIMETRES)
+		default:
+			err = errors.New("either text or image is required")
+		}
+		if err != nil {
+			return fmt.Errorf("watermark %d: %w", i+1, err)
+		}
+
+		pages, err := api.PagesForPageSelection(ctx.PageCount, wm.Pages, true, true)
+		if err != nil {
+			return fmt.Errorf("watermark %d: select pages: %w", i+1, err)
+		}
+		if err := api.WatermarkContext(ctx, pages, w); err != nil {
+			return fmt.Errorf("watermark %d: %w", i+1, err)
+		}
+	}
+
+	if err := api.Write(ctx, out, conf); err != nil {
+		return fmt.Errorf("write PDF: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package pdf_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/sehrgutesoftware/httpdf/internal/pdf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddWatermarks(t *testing.T) {
+	t.Run("it_adds_a_text_watermark", func(t *testing.T) {
+		rotation := 30.0
+		var out bytes.Buffer
+		err := pdf.AddWatermarks(testDocument(2), &out, []pdf.Watermark{{
+			Text:     "DRAFT",
+			Rotation: &rotation,
+			Opacity:  0.3,
+			Color:    "#ff0000",
+		}})
+
+		require.NoError(t, err)
+		hasWatermarks, err := api.HasWatermarks(bytes.NewReader(out.Bytes()), nil)
+		require.NoError(t, err)
+		assert.True(t, hasWatermarks)
+	})
+
+	t.Run("it_adds_an_image_stamp_to_selected_pages", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+		for x := range 8 {
+			img.Set(x, x, color.Black)
+		}
+		var imgData bytes.Buffer
+		require.NoError(t, png.Encode(&imgData, img))
+
+		var out bytes.Buffer
+		err := pdf.AddWatermarks(testDocument(3), &out, []pdf.Watermark{{
+			Image:    imgData.Bytes(),
+			OnTop:    true,
+			Position: "br",
+			OffsetX:  -10,
+			OffsetY:  10,
+			Scale:    0.2,
+			Pages:    []string{"2-"},
+		}})
+
+		require.NoError(t, err)
+		hasWatermarks, err := api.HasWatermarks(bytes.NewReader(out.Bytes()), nil)
+		require.NoError(t, err)
+		assert.True(t, hasWatermarks)
+	})
+
+	t.Run("it_adds_multiple_watermarks", func(t *testing.T) {
+		var out bytes.Buffer
+		err := pdf.AddWatermarks(testDocument(1), &out, []pdf.Watermark{
+			{Text: "COPY", OnTop: true},
+			{Text: "CONFIDENTIAL", Position: "tc", FontSize: 12},
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("it_returns_an_error_for_empty_watermarks", func(t *testing.T) {
+		err := pdf.AddWatermarks(testDocument(1), &bytes.Buffer{}, []pdf.Watermark{{}})
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "either text or image is required")
+	})
+
+	t.Run("it_returns_an_error_for_invalid_options", func(t *testing.T) {
+		err := pdf.AddWatermarks(testDocument(1), &bytes.Buffer{}, []pdf.Watermark{{
+			Text:     "DRAFT",
+			Position: "somewhere",
+		}})
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,87 @@
+package template
+
+import (
+	"github.com/kaptinlin/jsonschema"
+)
+
+// WithDefaults returns a copy of the values with the defaults declared by the
+// schema filled in, if applyDefaults is enabled in the config. Defaults are
+// applied to missing properties of objects, including nested objects and the
+// items of arrays present in the values; missing objects aren't created.
+// Without applyDefaults, the values are returned as they are.
+func (t *Template) WithDefaults(values map[string]any) map[string]any {
+	if !t.Config.ApplyDefaults || t.Schema == nil {
+		return values
+	}
+
+	withDefaults, _ := applyDefaults(t.Schema, copyValue(values)).(map[string]any)
+	return withDefaults
+}
+
+// applyDefaults fills in the defaults of the schema in the value, which it
+// modifies
+func applyDefaults(s *jsonschema.Schema, value any) any {
+	if s == nil {
+		return value
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if v == nil {
+			return v
+		}
+		if s.Properties != nil {
+			for name, property := range *s.Properties {
+				if current, ok := v[name]; ok {
+					v[name] = applyDefaults(property, current)
+				} else if property.Default != nil {
+					v[name] = applyDefaults(property, copyValue(property.Default))
+				}
+			}
+		}
+	case []any:
+		for i, item := range v {
+			if i < len(s.PrefixItems) {
+				v[i] = applyDefaults(s.PrefixItems[i], item)
+			} else {
+				v[i] = applyDefaults(s.Items, item)
+			}
+		}
+	}
+
+	// Properties can also be declared by referenced and combined schemas
+	if s.ResolvedRef != nil {
+		value = applyDefaults(s.ResolvedRef, value)
+	}
+	for _, sub := range s.AllOf {
+		value = applyDefaults(sub, value)
+	}
+	return value
+}
+
+// copyValue returns a deep copy of objects and arrays, so that applying
+// defaults neither modifies the caller's values nor the schema's defaults
+func copyValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		if v == nil {
+			return v
+		}
+		c := make(map[string]any, len(v))
+		for key, item := range v {
+			c[key] = copyValue(item)
+		}
+		return c
+	case []any:
+		if v == nil {
+			return v
+		}
+		c := make([]any, len(v))
+		for i, item := range v {
+			c[i] = copyValue(item)
+		}
+		return c
+	default:
+		return value
+	}
+}
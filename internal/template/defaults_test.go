@@ -0,0 +1,77 @@
+package template_test
+
+import (
+	"testing"
+
+	"github.com/kaptinlin/jsonschema"
+	"github.com/sehrgutesoftware/httpdf/internal/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func defaultsTemplate(t *testing.T, enabled bool) *template.Template {
+	t.Helper()
+	schema, err := jsonschema.NewCompiler().Compile([]byte(`{
+		"type": "object",
+		"$defs": {
+			"party": {"type": "object", "properties": {"country": {"type": "string", "default": "DE"}}}
+		},
+		"properties": {
+			"currency": {"type": "string", "default": "EUR"},
+			"title": {"type": "string", "default": "Invoice"},
+			"options": {"type": "object", "default": {"compact": false}},
+			"customer": {"$ref": "#/$defs/party"},
+			"seller": {"$ref": "#/$defs/party"},
+			"items": {
+				"type": "array",
+				"items": {"type": "object", "properties": {"quantity": {"type": "integer", "default": 1}}}
+			}
+		},
+		"allOf": [{"properties": {"locale": {"type": "string", "default": "de"}}}]
+	}`))
+	require.NoError(t, err)
+
+	tmpl := &template.Template{Schema: schema}
+	tmpl.Config.ApplyDefaults = enabled
+	return tmpl
+}
+
+func TestTemplate_WithDefaults(t *testing.T) {
+	t.Run("it_fills_in_missing_properties", func(t *testing.T) {
+		values := defaultsTemplate(t, true).WithDefaults(map[string]any{
+			"title":    "Credit note",
+			"customer": map[string]any{"name": "Jane"},
+			"items":    []any{map[string]any{"name": "a"}, map[string]any{"name": "b", "quantity": 3}},
+		})
+
+		assert.Equal(t, map[string]any{
+			"currency": "EUR",
+			"title":    "Credit note",
+			"options":  map[string]any{"compact": false},
+			"locale":   "de",
+			"customer": map[string]any{"name": "Jane", "country": "DE"},
+			"items": []any{
+				map[string]any{"name": "a", "quantity": 1.0},
+				map[string]any{"name": "b", "quantity": 3},
+			},
+		}, values)
+	})
+
+	t.Run("it_does_not_modify_the_values_or_the_defaults", func(t *testing.T) {
+		tmpl := defaultsTemplate(t, true)
+		values := map[string]any{"customer": map[string]any{}}
+
+		first := tmpl.WithDefaults(values)
+		first["options"].(map[string]any)["compact"] = true
+		second := tmpl.WithDefaults(values)
+
+		assert.Equal(t, map[string]any{"customer": map[string]any{}}, values)
+		assert.Equal(t, map[string]any{"compact": false}, second["options"])
+	})
+
+	t.Run("it_returns_the_values_as_they_are_if_disabled", func(t *testing.T) {
+		values := map[string]any{"title": "Credit note"}
+
+		assert.Equal(t, values, defaultsTemplate(t, false).WithDefaults(values))
+	})
+}
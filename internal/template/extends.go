@@ -0,0 +1,172 @@
+package template
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ErrInvalidExtends is returned if a template extends a template that doesn't
+// exist, or if templates extend each other in a cycle
+var ErrInvalidExtends = errors.New("invalid extends")
+
+// lineage returns the names of the templates the template extends, directly
+// or indirectly, starting with the base-most one and ending with name
+func (l *fsLoader) lineage(name string) ([]string, error) {
+	lineage := []string{name}
+	for current := name; ; {
+		var config struct {
+			Extends string `yaml:"extends"`
+		}
+		if err := l.decodeConfig([]string{current}, &config); err != nil {
+			return nil, err
+		}
+		base := config.Extends
+		if base == "" {
+			break
+		}
+		if !fs.ValidPath(base) || strings.ContainsAny(base, "/") || strings.HasPrefix(base, "_") || strings.HasPrefix(base, ".") {
+			return nil, fmt.Errorf("%w: %s extends %q, which isn't a template name", ErrInvalidExtends, current, base)
+		}
+		if slices.Contains(lineage, base) {
+			return nil, fmt.Errorf("%w: cycle %s", ErrInvalidExtends, strings.Join(append(lineage, base), " -> "))
+		}
+		if _, err := fs.Stat(l.root, path.Join(base, "config.yaml")); err != nil {
+			return nil, fmt.Errorf("%w: %s extends %s, which doesn't exist", ErrInvalidExtends, current, base)
+		}
+		lineage = append(lineage, base)
+		current = base
+	}
+	slices.Reverse(lineage)
+	return lineage, nil
+}
+
+// decodeConfig decodes the config.yaml files of the lineage into v, in order.
+// Mappings are merged key by key, so that a template only overrides the
+// values it sets, including those of nested mappings like a single
+// watermark's opacity. Lists and scalars replace the base's values.
+func (l *fsLoader) decodeConfig(lineage []string, v any) error {
+	var merged *yaml.Node
+	for _, name := range lineage {
+		f, err := l.root.Open(path.Join(name, "config.yaml"))
+		if err != nil {
+			return fmt.Errorf("open config file: %w", err)
+		}
+		var doc yaml.Node
+		err = yaml.NewDecoder(f).Decode(&doc)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("decode config file: %w", err)
+		}
+		merged = mergeYAML(merged, doc.Content[0])
+	}
+	if err := merged.Decode(v); err != nil {
+		return fmt.Errorf("decode config file: %w", err)
+	}
+	return nil
+}
+
+// mergeYAML merges the mapping over into base. Other nodes replace base.
+func mergeYAML(base, over *yaml.Node) *yaml.Node {
+	if base == nil || base.Kind != yaml.MappingNode || over.Kind != yaml.MappingNode {
+		return over
+	}
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: over.Tag, Content: slices.Clone(base.Content)}
+	for i := 0; i+1 < len(over.Content); i += 2 {
+		key, value := over.Content[i], over.Content[i+1]
+		j := -1
+		for k := 0; k+1 < len(merged.Content); k += 2 {
+			if merged.Content[k].Value == key.Value {
+				j = k
+				break
+			}
+		}
+		if j < 0 {
+			merged.Content = append(merged.Content, key, value)
+			continue
+		}
+		merged.Content[j+1] = mergeYAML(merged.Content[j+1], value)
+	}
+	return merged
+}
+
+// schemaDocument returns the schema of the last template of the lineage. If
+// it extends another template, the schemas are combined with allOf: each is
+// placed in $defs under the name of its template, and their references are
+// rebased accordingly. Templates without a schema.json inherit their base's.
+func (l *fsLoader) schemaDocument(lineage []string) ([]byte, error) {
+	name := lineage[len(lineage)-1]
+	p := path.Join(name, "schema.json")
+	own, err := fs.ReadFile(l.root, p)
+	if err != nil && !(errors.Is(err, fs.ErrNotExist) && len(lineage) > 1) {
+		return nil, fmt.Errorf("read schema file: %w", err)
+	}
+	if len(lineage) == 1 {
+		return own, nil
+	}
+
+	base, err := l.schemaDocument(lineage[:len(lineage)-1])
+	if err != nil || own == nil {
+		return base, err
+	}
+
+	defs := make(map[string]any, 2)
+	allOf := make([]any, 0, 2)
+	for _, s := range []struct {
+		name string
+		data []byte
+	}{{lineage[len(lineage)-2], base}, {name, own}} {
+		var schema any
+		if err := json.Unmarshal(s.data, &schema); err != nil {
+			return nil, fmt.Errorf("decode schema of %s: %w", s.name, err)
+		}
+		prefix := "#/$defs/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(s.name)
+		defs[s.name] = rebaseRefs(schema, prefix)
+		allOf = append(allOf, map[string]any{"$ref": prefix})
+	}
+	return json.Marshal(map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$defs":   defs,
+		"allOf":   allOf,
+	})
+}
+
+// rebaseRefs prefixes the local references below v, so that they resolve
+// once v is moved to the location prefix points to
+func rebaseRefs(v any, prefix string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if ref, ok := value.(string); ok && key == "$ref" && strings.HasPrefix(ref, "#") {
+				v[key] = prefix + strings.TrimPrefix(ref, "#")
+				continue
+			}
+			v[key] = rebaseRefs(value, prefix)
+		}
+	case []any:
+		for i := range v {
+			v[i] = rebaseRefs(v[i], prefix)
+		}
+	}
+	return v
+}
+
+// layeredFS looks up files in its layers in order, so that files of the first
+// layers hide those of the following ones
+type layeredFS []fs.FS
+
+func (l layeredFS) Open(name string) (fs.File, error) {
+	for _, layer := range l {
+		f, err := layer.Open(name)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return f, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
@@ -0,0 +1,312 @@
+package template
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/kaptinlin/jsonschema"
+)
+
+// InferSchema proposes a draft schema from the fields referenced by the
+// template: fields it ranges over become arrays, fields with fields of their
+// own objects, and the types of all others are left open
+func (t *Template) InferSchema() (map[string]any, error) {
+	parsed, err := t.parseTemplate(t.funcs("", ""))
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+
+	s := inferFields(parsed).schema()
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["type"] = "object"
+	return s, nil
+}
+
+// UndeclaredFields returns the fields referenced by the template, but not
+// declared by its schema, e.g. customer.name or items[].price
+func (t *Template) UndeclaredFields() ([]string, error) {
+	parsed, err := t.parseTemplate(t.funcs("", ""))
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+
+	var paths []string
+	undeclared(inferFields(parsed), t.Schema, "", &paths)
+	return paths, nil
+}
+
+// field is a value referenced by the template, along with the fields
+// referenced on it in turn
+type field struct {
+	fields map[string]*field
+	// items is the element, if the template ranges over the value
+	items *field
+}
+
+func newField() *field {
+	return &field{fields: make(map[string]*field)}
+}
+
+// child returns the field of the value with the given name
+func (f *field) child(name string) *field {
+	if f.fields[name] == nil {
+		f.fields[name] = newField()
+	}
+	return f.fields[name]
+}
+
+// elem returns the element of the value
+func (f *field) elem() *field {
+	if f.items == nil {
+		f.items = newField()
+	}
+	return f.items
+}
+
+// resolve returns the field at the path below f, or nil if f is unknown
+func (f *field) resolve(path []string) *field {
+	if f == nil {
+		return nil
+	}
+	for _, name := range path {
+		f = f.child(name)
+	}
+	return f
+}
+
+// schema returns a draft schema of the value. Leaves have no type, as the
+// template doesn't tell how they are used.
+func (f *field) schema() map[string]any {
+	s := make(map[string]any)
+	if len(f.fields) > 0 {
+		properties := make(map[string]any, len(f.fields))
+		for name, child := range f.fields {
+			properties[name] = child.schema()
+		}
+		s["type"] = "object"
+		s["properties"] = properties
+	}
+	if f.items != nil {
+		if len(f.fields) > 0 {
+			// The value is used as object and as list
+			delete(s, "type")
+		} else {
+			s["type"] = "array"
+		}
+		s["items"] = f.items.schema()
+	}
+	return s
+}
+
+// scope is the dot and the variables at a position of the template. Values
+// that can't be traced back to the data, like the results of functions, are
+// nil.
+type scope struct {
+	dot  *field
+	vars map[string]*field
+}
+
+// nested returns the scope of the body of a control structure, whose
+// variables are discarded at its end
+func (s scope) nested() scope {
+	return scope{dot: s.dot, vars: maps.Clone(s.vars)}
+}
+
+// inferrer collects the fields referenced by a parsed template
+type inferrer struct {
+	templates map[string]*parse.Tree
+	// calling guards against recursive template calls
+	calling map[string]bool
+}
+
+// inferFields returns the data fields referenced by the template, following
+// the dot through range, with and template calls
+func inferFields(parsed *template.Template) *field {
+	in := &inferrer{
+		templates: make(map[string]*parse.Tree),
+		calling:   map[string]bool{parsed.Name(): true},
+	}
+	for _, t := range parsed.Templates() {
+		in.templates[t.Name()] = t.Tree
+	}
+
+	root := newField()
+	if parsed.Tree != nil {
+		in.list(parsed.Tree.Root, scope{dot: root, vars: map[string]*field{"$": root}})
+	}
+	return root
+}
+
+func (in *inferrer) list(l *parse.ListNode, s scope) {
+	if l == nil {
+		return
+	}
+	for _, node := range l.Nodes {
+		switch n := node.(type) {
+		case *parse.ActionNode:
+			in.pipe(n.Pipe, s)
+		case *parse.IfNode:
+			body := s.nested()
+			in.pipe(n.Pipe, body)
+			in.list(n.List, body)
+			in.list(n.ElseList, s.nested())
+		case *parse.WithNode:
+			body := s.nested()
+			body.dot = in.pipe(n.Pipe, body)
+			in.list(n.List, body)
+			in.list(n.ElseList, s.nested())
+		case *parse.RangeNode:
+			body := s.nested()
+			var elem *field
+			if value := in.pipe(n.Pipe, body); value != nil {
+				elem = value.elem()
+			}
+			// With two variables, the first one is the index or key
+			if decl := n.Pipe.Decl; len(decl) > 0 {
+				body.vars[decl[len(decl)-1].Ident[0]] = elem
+				if len(decl) > 1 {
+					body.vars[decl[0].Ident[0]] = nil
+				}
+			}
+			body.dot = elem
+			in.list(n.List, body)
+			in.list(n.ElseList, s.nested())
+		case *parse.TemplateNode:
+			dot := in.pipe(n.Pipe, s)
+			tree := in.templates[n.Name]
+			if tree == nil || in.calling[n.Name] {
+				continue
+			}
+			in.calling[n.Name] = true
+			in.list(tree.Root, scope{dot: dot, vars: map[string]*field{"$": dot}})
+			delete(in.calling, n.Name)
+		}
+	}
+}
+
+// pipe collects the fields referenced by the pipeline and declares its
+// variables. It returns the field the pipeline evaluates to, if it's a plain
+// reference.
+func (in *inferrer) pipe(p *parse.PipeNode, s scope) *field {
+	if p == nil {
+		return nil
+	}
+
+	var result *field
+	for _, cmd := range p.Cmds {
+		var refs []*field
+		for _, arg := range cmd.Args {
+			refs = append(refs, in.arg(arg, s))
+		}
+		if len(p.Cmds) == 1 && len(refs) == 1 {
+			result = refs[0]
+		}
+	}
+	for _, v := range p.Decl {
+		s.vars[v.Ident[0]] = result
+	}
+	return result
+}
+
+// arg returns the field referenced by the argument of a command
+func (in *inferrer) arg(node parse.Node, s scope) *field {
+	switch n := node.(type) {
+	case *parse.DotNode:
+		return s.dot
+	case *parse.FieldNode:
+		return s.dot.resolve(n.Ident)
+	case *parse.VariableNode:
+		return s.vars[n.Ident[0]].resolve(n.Ident[1:])
+	case *parse.ChainNode:
+		return in.arg(n.Node, s).resolve(n.Field)
+	case *parse.PipeNode:
+		return in.pipe(n, s.nested())
+	default:
+		return nil
+	}
+}
+
+// undeclared appends the paths of the fields below f which the schema doesn't
+// declare. Fields below an undeclared one aren't reported separately.
+func undeclared(f *field, s *jsonschema.Schema, path string, paths *[]string) {
+	for _, name := range slices.Sorted(maps.Keys(f.fields)) {
+		p := name
+		if path != "" {
+			p = path + "." + name
+		}
+		property := propertySchema(s, name)
+		if property == nil {
+			*paths = append(*paths, p)
+			continue
+		}
+		undeclared(f.fields[name], property, p, paths)
+	}
+	if f.items != nil {
+		undeclared(f.items, itemsSchema(s), path+"[]", paths)
+	}
+}
+
+// propertySchema returns the schema of the property, or nil if the schema
+// doesn't declare it
+func propertySchema(s *jsonschema.Schema, name string) *jsonschema.Schema {
+	if s == nil {
+		return nil
+	}
+	if s.Properties != nil {
+		if property, ok := (*s.Properties)[name]; ok {
+			return property
+		}
+	}
+	if s.PatternProperties != nil {
+		for pattern, property := range *s.PatternProperties {
+			if matched, _ := regexp.MatchString(pattern, name); matched {
+				return property
+			}
+		}
+	}
+	for _, sub := range subschemas(s) {
+		if property := propertySchema(sub, name); property != nil {
+			return property
+		}
+	}
+	// Maps declare their keys only through additionalProperties
+	if s.AdditionalProperties != nil && s.AdditionalProperties.Boolean == nil {
+		return s.AdditionalProperties
+	}
+	return nil
+}
+
+// itemsSchema returns the schema of the elements of an array or a map
+func itemsSchema(s *jsonschema.Schema) *jsonschema.Schema {
+	if s == nil {
+		return nil
+	}
+	if s.Items != nil {
+		return s.Items
+	}
+	if s.AdditionalProperties != nil && s.AdditionalProperties.Boolean == nil {
+		return s.AdditionalProperties
+	}
+	for _, sub := range subschemas(s) {
+		if items := itemsSchema(sub); items != nil {
+			return items
+		}
+	}
+	return nil
+}
+
+// subschemas returns the schemas the schema is composed of
+func subschemas(s *jsonschema.Schema) []*jsonschema.Schema {
+	var subs []*jsonschema.Schema
+	if s.ResolvedRef != nil {
+		subs = append(subs, s.ResolvedRef)
+	}
+	subs = append(subs, s.AllOf...)
+	subs = append(subs, s.AnyOf...)
+	subs = append(subs, s.OneOf...)
+	return subs
+}
@@ -0,0 +1,114 @@
+package template_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/sehrgutesoftware/httpdf/internal/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// inferTemplate loads a template with the given content and schema
+func inferTemplate(t *testing.T, content, schema string) *template.Template {
+	t.Helper()
+	tmpl, err := template.NewFSLoader(fstest.MapFS{
+		"t/template.html": &fstest.MapFile{Data: []byte(content)},
+		"t/config.yaml":   &fstest.MapFile{Data: []byte("page:\n  width: 210\n  height: 297\n")},
+		"t/schema.json":   &fstest.MapFile{Data: []byte(schema)},
+	}).Load("t")
+	require.NoError(t, err)
+	return tmpl
+}
+
+func TestTemplate_InferSchema(t *testing.T) {
+	t.Run("it_proposes_a_schema_from_the_referenced_fields", func(t *testing.T) {
+		tmpl := inferTemplate(t, `
+			{{ define "address" }}{{ .street }}{{ end }}
+			<h1>{{ .title | upper }}</h1>
+			{{ with .customer }}{{ .name }}{{ template "address" .address }}{{ end }}
+			{{ range $i, $item := .items }}{{ $item.name }}{{ printf "%.2f" .price }}{{ $.currency }}{{ end }}
+			{{ $total := .totals }}{{ $total.gross }}
+			{{ if .notes }}{{ end }}
+			{{ range chunk .list 2 }}{{ .ignored }}{{ end }}
+		`, `{}`)
+
+		s, err := tmpl.InferSchema()
+
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{
+			"$schema": "https://json-schema.org/draft/2020-12/schema",
+			"type":    "object",
+			"properties": map[string]any{
+				"title": map[string]any{},
+				"customer": map[string]any{"type": "object", "properties": map[string]any{
+					"name": map[string]any{},
+					"address": map[string]any{"type": "object", "properties": map[string]any{
+						"street": map[string]any{},
+					}},
+				}},
+				"items": map[string]any{"type": "array", "items": map[string]any{"type": "object", "properties": map[string]any{
+					"name":  map[string]any{},
+					"price": map[string]any{},
+				}}},
+				"currency": map[string]any{},
+				"totals": map[string]any{"type": "object", "properties": map[string]any{
+					"gross": map[string]any{},
+				}},
+				"notes": map[string]any{},
+				"list":  map[string]any{},
+			},
+		}, s)
+	})
+
+	t.Run("it_returns_an_error_for_invalid_templates", func(t *testing.T) {
+		tmpl := inferTemplate(t, `{{ if .name }}`, `{}`)
+
+		_, err := tmpl.InferSchema()
+
+		assert.Error(t, err)
+	})
+}
+
+func TestTemplate_UndeclaredFields(t *testing.T) {
+	t.Run("it_returns_the_fields_not_declared_in_the_schema", func(t *testing.T) {
+		tmpl := inferTemplate(t,
+			`{{ .title }}{{ .customer.name }}{{ .customer.email }}{{ .extra.value }}{{ range .items }}{{ .name }}{{ .price }}{{ end }}`,
+			`{
+				"type": "object",
+				"properties": {
+					"title": {"type": "string"},
+					"customer": {"type": "object", "properties": {"name": {"type": "string"}}},
+					"items": {"type": "array", "items": {"type": "object", "properties": {"name": {"type": "string"}}}}
+				}
+			}`,
+		)
+
+		fields, err := tmpl.UndeclaredFields()
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"customer.email", "extra", "items[].price"}, fields)
+	})
+
+	t.Run("it_follows_references_and_composition", func(t *testing.T) {
+		tmpl := inferTemplate(t,
+			`{{ .sender.name }}{{ .recipient.name }}{{ .number }}{{ .labels.de }}{{ range .counts }}{{ . }}{{ end }}`,
+			`{
+				"$defs": {"party": {"type": "object", "properties": {"name": {"type": "string"}}}},
+				"allOf": [
+					{"properties": {"sender": {"$ref": "#/$defs/party"}}},
+					{"properties": {"recipient": {"$ref": "#/$defs/party"}, "number": {"type": "string"}}}
+				],
+				"properties": {
+					"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+					"counts": {"type": "object", "additionalProperties": {"type": "integer"}}
+				}
+			}`,
+		)
+
+		fields, err := tmpl.UndeclaredFields()
+
+		require.NoError(t, err)
+		assert.Empty(t, fields)
+	})
+}
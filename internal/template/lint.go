@@ -0,0 +1,289 @@
+package template
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"text/template"
+	"text/template/parse"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// LintIssue is a problem found by Lint
+type LintIssue struct {
+	// Check is the check that found the issue: load, template, example,
+	// schema, execute, asset or locale
+	Check   string
+	Message string
+}
+
+// String formats the issue for reports
+func (i LintIssue) String() string {
+	return i.Check + ": " + i.Message
+}
+
+// Lint loads the template and checks that the template parses, that the
+// schema declares all fields the template uses, that all examples match the
+// schema and execute without errors, that all referenced assets exist and that
+// all translation keys exist in every configured locale. Assets and keys are
+// collected from string literals in the template as well as from the calls
+// made while executing the examples.
+func (l *fsLoader) Lint(name string) []LintIssue {
+	tmpl, err := l.load(name)
+	if err != nil {
+		return []LintIssue{{Check: "load", Message: err.Error()}}
+	}
+
+	var issues []LintIssue
+	report := func(check, format string, args ...any) {
+		issues = append(issues, LintIssue{Check: check, Message: fmt.Sprintf(format, args...)})
+	}
+
+	examples := maps.Clone(tmpl.Examples)
+	if examples == nil {
+		examples = make(map[string]map[string]any)
+	}
+	if tmpl.Example != nil {
+		examples["example.json"] = tmpl.Example
+	}
+	names := slices.Sorted(maps.Keys(examples))
+	for _, example := range names {
+		if valid := tmpl.Schema.Validate(examples[example]); !valid.Valid {
+			report("example", "%s doesn't match the schema: %v", example, valid.Errors)
+		}
+	}
+
+	assets := make(map[string]bool)
+	keys := make(map[string]bool)
+	funcs := tmpl.funcs("", "")
+	asset := funcs["asset"].(func(...string) string)
+	funcs["asset"] = func(p ...string) string {
+		assets[path.Join(p...)] = true
+		return asset(p...)
+	}
+	tr := funcs["tr"].(func(string, ...any) string)
+	funcs["tr"] = func(key string, args ...any) string {
+		keys[key] = true
+		return tr(key, args...)
+	}
+	trLocale := funcs["trLocale"].(func(string, string, ...any) string)
+	funcs["trLocale"] = func(locale, key string, args ...any) string {
+		keys[key] = true
+		return trLocale(locale, key, args...)
+	}
+
+	parsed, err := tmpl.parseTemplate(funcs)
+	if err != nil {
+		report("template", "%v", err)
+		return issues
+	}
+	for _, o := range tmpl.Overrides {
+		if t := parsed.Lookup(o.Name); t != nil && t.Tree != nil && hasContent(t.Tree.Root) {
+			report("template", "%s: content outside of define and block is ignored, as the template extends another one", o.Name)
+		}
+	}
+
+	// Partials only count if the template uses them
+	shared := make(map[string]bool)
+	for _, p := range tmpl.Partials {
+		shared[p.Name] = true
+	}
+	called := calledTemplates(parsed, shared)
+	for _, t := range parsed.Templates() {
+		if t.Tree != nil && (!shared[t.Tree.ParseName] || called[t.Name()]) {
+			collectLiterals(t.Tree.Root, assets, keys)
+		}
+	}
+	var fields []string
+	undeclared(inferFields(parsed), tmpl.Schema, "", &fields)
+	for _, p := range fields {
+		report("schema", "%s is used by the template, but not declared in the schema", p)
+	}
+	for _, example := range names {
+		if err := parsed.Execute(io.Discard, tmpl.WithDefaults(examples[example])); err != nil {
+			report("execute", "%s: %v", example, missingKeyError(parsed, tmpl.sources(), err))
+		}
+	}
+
+	for _, p := range slices.Sorted(maps.Keys(assets)) {
+		if tmpl.Assets == nil {
+			report("asset", "%s doesn't exist, the template has no assets directory", p)
+		} else if _, err := fs.Stat(tmpl.Assets, p); err != nil {
+			report("asset", "%s doesn't exist", p)
+		}
+	}
+
+	if len(keys) > 0 && tmpl.Config.Locale == nil {
+		report("locale", "translation keys are used, but no locales are configured")
+	} else if len(keys) > 0 {
+		for _, locale := range tmpl.Config.Locale.Locales {
+			translations, err := l.loadTranslations(name, locale)
+			if err != nil {
+				report("locale", "%v", err)
+				continue
+			}
+			for _, key := range slices.Sorted(maps.Keys(keys)) {
+				if _, ok := translations[key]; !ok {
+					report("locale", "key %q is missing in %s", key, locale)
+				}
+			}
+		}
+	}
+
+	return issues
+}
+
+// loadTranslations reads the translation files of the locale, including those
+// of the templates it extends. The bundle can't be used to check for keys, as
+// it falls back to the default locale.
+func (l *fsLoader) loadTranslations(name, locale string) (map[string]string, error) {
+	lineage, err := l.lineage(name)
+	if err != nil {
+		return nil, err
+	}
+
+	translations := make(map[string]string)
+	found := false
+	for _, n := range lineage {
+		p := path.Join(n, "locales", locale+".yaml")
+		data, err := fs.ReadFile(l.root, p)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("read %s: %w", p, err)
+		}
+		if err := yaml.Unmarshal(data, &translations); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", p, err)
+		}
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("read %s: %w", path.Join(name, "locales", locale+".yaml"), fs.ErrNotExist)
+	}
+	return translations, nil
+}
+
+// collectLiterals collects the asset paths and translation keys passed as
+// string literals to the asset, tr and trLocale functions
+func collectLiterals(node parse.Node, assets, keys map[string]bool) {
+	walk(node, func(n parse.Node) {
+		cmd, ok := n.(*parse.CommandNode)
+		if !ok || len(cmd.Args) == 0 {
+			return
+		}
+		ident, ok := cmd.Args[0].(*parse.IdentifierNode)
+		if !ok {
+			return
+		}
+		literals := make([]string, 0, len(cmd.Args)-1)
+		for _, arg := range cmd.Args[1:] {
+			s, ok := arg.(*parse.StringNode)
+			if !ok {
+				break
+			}
+			literals = append(literals, s.Text)
+		}
+
+		switch {
+		case ident.Ident == "asset" && len(literals) > 0 && len(literals) == len(cmd.Args)-1:
+			assets[path.Join(literals...)] = true
+		case ident.Ident == "tr" && len(literals) > 0:
+			keys[literals[0]] = true
+		case ident.Ident == "trLocale" && len(literals) > 1:
+			keys[literals[1]] = true
+		}
+	})
+}
+
+// calledTemplates returns the names of the templates called by the parsed
+// template, directly or through other templates. Only calls from templates
+// not parsed from the shared files count as direct calls.
+func calledTemplates(parsed *template.Template, shared map[string]bool) map[string]bool {
+	called := make(map[string]bool)
+	var visit func(*template.Template)
+	visit = func(t *template.Template) {
+		if t == nil || t.Tree == nil {
+			return
+		}
+		walk(t.Tree.Root, func(n parse.Node) {
+			if call, ok := n.(*parse.TemplateNode); ok && !called[call.Name] {
+				called[call.Name] = true
+				visit(parsed.Lookup(call.Name))
+			}
+		})
+	}
+	for _, t := range parsed.Templates() {
+		if t.Tree != nil && !shared[t.Tree.ParseName] {
+			visit(t)
+		}
+	}
+	return called
+}
+
+// hasContent reports whether the list contains anything but whitespace and
+// template calls, which is the case of blocks
+func hasContent(list *parse.ListNode) bool {
+	for _, n := range list.Nodes {
+		switch n := n.(type) {
+		case *parse.TextNode:
+			if len(bytes.TrimSpace(n.Text)) > 0 {
+				return true
+			}
+		case *parse.TemplateNode:
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// walk calls fn for the node and all nodes below it
+func walk(node parse.Node, fn func(parse.Node)) {
+	if node == nil {
+		return
+	}
+	fn(node)
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walk(child, fn)
+		}
+	case *parse.ActionNode:
+		walk(n.Pipe, fn)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walk(cmd, fn)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walk(arg, fn)
+		}
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.TemplateNode:
+		walk(n.Pipe, fn)
+	}
+}
+
+func walkBranch(n *parse.BranchNode, fn func(parse.Node)) {
+	walk(n.Pipe, fn)
+	walk(n.List, fn)
+	walk(n.ElseList, fn)
+}
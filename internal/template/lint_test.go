@@ -0,0 +1,180 @@
+package template_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/sehrgutesoftware/httpdf/internal/template"
+	"github.com/stretchr/testify/assert"
+)
+
+// lintFS returns a valid template, with the files replaced by the given ones
+func lintFS(files map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{
+		"hello/template.html": &fstest.MapFile{Data: []byte(
+			`<link href="{{ asset "style.css" }}"><h1>{{ tr "greeting" }} {{ .name }}</h1>`,
+		)},
+		"hello/config.yaml": &fstest.MapFile{Data: []byte("locale:\n  locales: [en, de]\n  default: en\n")},
+		"hello/schema.json": &fstest.MapFile{Data: []byte(
+			`{"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"]}`,
+		)},
+		"hello/example.json":     &fstest.MapFile{Data: []byte(`{"name": "World"}`)},
+		"hello/assets/style.css": &fstest.MapFile{Data: []byte(`h1 { color: red }`)},
+		"hello/locales/en.yaml":  &fstest.MapFile{Data: []byte(`greeting: Hello`)},
+		"hello/locales/de.yaml":  &fstest.MapFile{Data: []byte(`greeting: Hallo`)},
+	}
+	for name, data := range files {
+		if data == "" {
+			delete(fsys, "hello/"+name)
+			continue
+		}
+		fsys["hello/"+name] = &fstest.MapFile{Data: []byte(data)}
+	}
+	return fsys
+}
+
+func TestFSLoader_Lint(t *testing.T) {
+	t.Run("it_returns_no_issues_for_a_valid_template", func(t *testing.T) {
+		issues := template.NewFSLoader(lintFS(nil)).Lint("hello")
+
+		assert.Empty(t, issues)
+	})
+
+	t.Run("it_reports_templates_that_fail_to_load", func(t *testing.T) {
+		issues := template.NewFSLoader(lintFS(map[string]string{"schema.json": `{`})).Lint("hello")
+
+		assert.Len(t, issues, 1)
+		assert.Equal(t, "load", issues[0].Check)
+	})
+
+	t.Run("it_reports_syntax_errors", func(t *testing.T) {
+		issues := template.NewFSLoader(lintFS(map[string]string{"template.html": `{{ if .name }}`})).Lint("hello")
+
+		assert.Len(t, issues, 1)
+		assert.Equal(t, "template", issues[0].Check)
+	})
+
+	t.Run("it_reports_all_examples_not_matching_the_schema", func(t *testing.T) {
+		issues := template.NewFSLoader(lintFS(map[string]string{
+			"example.json":        `{}`,
+			"examples/empty.json": `{"name": 42}`,
+		})).Lint("hello")
+
+		assert.Equal(t, []string{"example", "example"}, checks(issues))
+		assert.Contains(t, issues[0].Message, "empty")
+		assert.Contains(t, issues[1].Message, "example.json")
+	})
+
+	t.Run("it_reports_fields_not_declared_in_the_schema", func(t *testing.T) {
+		issues := template.NewFSLoader(lintFS(map[string]string{
+			"template.html": `{{ .name }}{{ .company.name }}`,
+		})).Lint("hello")
+
+		assert.Equal(t, []string{"schema: company is used by the template, but not declared in the schema"}, messages(issues))
+	})
+
+	t.Run("it_reports_examples_failing_to_execute", func(t *testing.T) {
+		issues := template.NewFSLoader(lintFS(map[string]string{
+			"template.html": `{{ index .name 5 }}`,
+		})).Lint("hello")
+
+		assert.Equal(t, []string{"execute"}, checks(issues))
+	})
+
+	t.Run("it_reports_missing_keys_of_examples_in_error_mode", func(t *testing.T) {
+		issues := template.NewFSLoader(lintFS(map[string]string{
+			"config.yaml":   "missingKey: error\nlocale:\n  locales: [en, de]\n  default: en\n",
+			"schema.json":   `{"type": "object", "properties": {"name": {"type": "string"}, "title": {"type": "string"}}}`,
+			"template.html": `{{ tr "greeting" }} {{ .title }} {{ .name }}`,
+		})).Lint("hello")
+
+		assert.Equal(t, []string{`execute: example.json: hello/template.html:1:24: .title: missing key "title"`}, messages(issues))
+	})
+
+	t.Run("it_reports_invalid_missing_key_modes", func(t *testing.T) {
+		issues := template.NewFSLoader(lintFS(map[string]string{"config.yaml": "missingKey: strict\n"})).Lint("hello")
+
+		assert.Equal(t, []string{"load"}, checks(issues))
+	})
+
+	t.Run("it_reports_missing_assets", func(t *testing.T) {
+		issues := template.NewFSLoader(lintFS(map[string]string{
+			"template.html": `{{ asset "style.css" }}{{ asset "images" "logo.png" }}{{ if false }}{{ asset "unused.css" }}{{ end }}`,
+		})).Lint("hello")
+
+		assert.Equal(t, []string{"asset: images/logo.png doesn't exist", "asset: unused.css doesn't exist"}, messages(issues))
+	})
+
+	t.Run("it_checks_the_shared_partials_used_by_the_template", func(t *testing.T) {
+		fsys := lintFS(map[string]string{
+			"template.html": `{{ template "logo" }}{{ tr "greeting" }} {{ .name }}`,
+		})
+		fsys["_partials/logo.html"] = &fstest.MapFile{Data: []byte(`{{ define "logo" }}{{ asset "logo.png" }}{{ end }}`)}
+		fsys["_partials/unused.html"] = &fstest.MapFile{Data: []byte(`{{ define "unused" }}{{ asset "unused.png" }}{{ end }}`)}
+
+		issues := template.NewFSLoader(fsys).Lint("hello")
+
+		assert.Equal(t, []string{"asset: logo.png doesn't exist"}, messages(issues))
+	})
+
+	t.Run("it_checks_extending_templates_against_their_base", func(t *testing.T) {
+		fsys := lintFS(nil)
+		fsys["letter/config.yaml"] = &fstest.MapFile{Data: []byte("extends: hello\n")}
+		fsys["letter/template.html"] = &fstest.MapFile{Data: []byte(`{{ define "x" }}{{ asset "style.css" }}{{ tr "greeting" }}{{ tr "closing" }}{{ end }}`)}
+		fsys["letter/locales/de.yaml"] = &fstest.MapFile{Data: []byte(`closing: Tschüss`)}
+
+		issues := template.NewFSLoader(fsys).Lint("letter")
+
+		assert.Equal(t, []string{`locale: key "closing" is missing in en`}, messages(issues))
+	})
+
+	t.Run("it_reports_content_ignored_by_extending_templates", func(t *testing.T) {
+		fsys := lintFS(nil)
+		fsys["letter/config.yaml"] = &fstest.MapFile{Data: []byte("extends: hello\n")}
+		fsys["letter/template.html"] = &fstest.MapFile{Data: []byte("\n{{ define \"x\" }}x{{ end }}\n<p>Ignored</p>\n")}
+
+		issues := template.NewFSLoader(fsys).Lint("letter")
+
+		assert.Equal(t, []string{"template"}, checks(issues))
+		assert.Contains(t, issues[0].Message, "letter/template.html")
+	})
+
+	t.Run("it_reports_assets_referenced_at_runtime", func(t *testing.T) {
+		issues := template.NewFSLoader(lintFS(map[string]string{
+			"template.html": `{{ asset (printf "%s.css" .name) }}`,
+		})).Lint("hello")
+
+		assert.Equal(t, []string{"asset: World.css doesn't exist"}, messages(issues))
+	})
+
+	t.Run("it_reports_translation_keys_missing_in_a_locale", func(t *testing.T) {
+		issues := template.NewFSLoader(lintFS(map[string]string{
+			"template.html":   `{{ tr "greeting" }}{{ trLocale "en" "farewell" }}`,
+			"locales/en.yaml": "greeting: Hello\nfarewell: Bye",
+		})).Lint("hello")
+
+		assert.Equal(t, []string{`locale: key "farewell" is missing in de`}, messages(issues))
+	})
+
+	t.Run("it_reports_missing_locale_files", func(t *testing.T) {
+		issues := template.NewFSLoader(lintFS(map[string]string{"locales/de.yaml": ""})).Lint("hello")
+
+		assert.Equal(t, []string{"locale"}, checks(issues))
+	})
+}
+
+func checks(issues []template.LintIssue) []string {
+	var checks []string
+	for _, issue := range issues {
+		checks = append(checks, issue.Check)
+	}
+	return checks
+}
+
+func messages(issues []template.LintIssue) []string {
+	var messages []string
+	for _, issue := range issues {
+		messages = append(messages, issue.String())
+	}
+	return messages
+}
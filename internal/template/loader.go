@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"io/fs"
+	"maps"
 	"path"
+	"slices"
+	"strings"
 
 	"github.com/kaptinlin/go-i18n"
 	"github.com/kaptinlin/jsonschema"
@@ -16,12 +18,31 @@ import (
 var (
 	// ErrTemplateNotFound is returned when a template is not found
 	ErrTemplateNotFound = errors.New("template not found")
+	// ErrInvalidExample is returned when a named example doesn't match the
+	// template's schema
+	ErrInvalidExample = errors.New("invalid example")
 )
 
+// sharedDirs are the directories at the root of the loader, whose *.html files
+// are available to all templates as partials. Layouts are added before
+// partials; a name must not be defined in more than one of their files.
+var sharedDirs = []string{"_layouts", "_partials"}
+
+// SharedDirs returns the directories at the root of the loader, whose *.html
+// files are available to all templates
+func SharedDirs() []string {
+	return slices.Clone(sharedDirs)
+}
+
 // Loader allows access to templates
 type Loader interface {
 	// Load loads a template by name
 	Load(name string) (*Template, error)
+	// List returns the names of all templates, sorted
+	List() ([]string, error)
+	// Lint checks a template for errors that would otherwise only surface
+	// when rendering it. It returns nil if no issues were found.
+	Lint(name string) []LintIssue
 }
 
 // fsLoader is a Loader implementation that loads templates from a filesystem.
@@ -33,6 +54,12 @@ type Loader interface {
 // - dir/schema.json: The JSON schema file
 // - dir/assets: (optional) directory containing static assets
 // - dir/locales/{locale}.yaml: (optional) translation files
+// - dir/example.json: (optional) example data for the preview
+// - dir/examples/{name}.json: (optional) named example data for the preview
+//
+// The templates defined in _layouts/*.html and _partials/*.html are available
+// to all templates. A template extending another one by config.yaml may omit
+// template.html and schema.json.
 type fsLoader struct {
 	root   fs.SubFS
 	schema *jsonschema.Compiler
@@ -48,48 +75,87 @@ func NewFSLoader(root fs.SubFS) Loader {
 
 // Load loads a template from the filesystem
 func (l *fsLoader) Load(name string) (*Template, error) {
-	contentPath := path.Join(name, "template.html")
+	tmpl, err := l.load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// The named examples are validated, as they are meant to cover edge cases
+	// of the schema.
+	for _, example := range slices.Sorted(maps.Keys(tmpl.Examples)) {
+		if valid := tmpl.Schema.Validate(tmpl.Examples[example]); !valid.Valid {
+			p := path.Join(name, "examples", example+".json")
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidExample, p, valid.Errors)
+		}
+	}
+
+	return tmpl, nil
+}
+
+// List returns the names of the directories containing a template.html or,
+// for templates extending another one, a config.yaml
+func (l *fsLoader) List() ([]string, error) {
+	entries, err := fs.ReadDir(l.root, ".")
+	if err != nil {
+		return nil, fmt.Errorf("list templates: %w", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		for _, file := range []string{"template.html", "config.yaml"} {
+			if _, err := fs.Stat(l.root, path.Join(e.Name(), file)); err == nil {
+				names = append(names, e.Name())
+				break
+			}
+		}
+	}
+	return names, nil
+}
+
+// load loads a template from the filesystem without validating the examples.
+// If the template extends another one, the config, assets, locales and schema
+// of the base are inherited.
+func (l *fsLoader) load(name string) (*Template, error) {
 	configPath := path.Join(name, "config.yaml")
-	schemaPath := path.Join(name, "schema.json")
-	assetsPath := path.Join(name, "assets")
 	examplePath := path.Join(name, "example.json")
-	localesGlob := path.Join(name, "locales", "*.yaml")
+	examplesGlob := path.Join(name, "examples", "*.json")
 
 	// Ensure that all required files exist. Possible TOCTOU issue here, but
 	// errors later on will still be handled – though the error message will
 	// be different (i.e. will not return ErrTemplateNotFound)
-	for _, p := range []string{contentPath, configPath, schemaPath} {
-		_, err := fs.Stat(l.root, p)
-		if errors.Is(err, fs.ErrNotExist) {
-			return nil, fmt.Errorf("%w: missing %s", ErrTemplateNotFound, p)
-		} else if err != nil {
-			return nil, fmt.Errorf("stat file: %w", err)
+	if err := l.require(configPath); err != nil {
+		return nil, err
+	}
+	lineage, err := l.lineage(name)
+	if err != nil {
+		return nil, err
+	}
+	// template.html and schema.json may be inherited from the base
+	base := lineage[0]
+	contentPath := path.Join(base, "template.html")
+	for _, p := range []string{contentPath, path.Join(base, "schema.json")} {
+		if err := l.require(p); err != nil {
+			return nil, err
 		}
 	}
 
-	var err error
-	tmpl := &Template{}
+	tmpl := &Template{file: contentPath, Bases: lineage[:len(lineage)-1]}
 
-	// Load the config
-	configFile, err := l.root.Open(configPath)
-	if err != nil {
-		return nil, fmt.Errorf("open config file: %w", err)
+	// Load the config, the extending templates' overriding their base's
+	if err := l.decodeConfig(lineage, &tmpl.Config); err != nil {
+		return nil, err
 	}
-	defer configFile.Close()
-	err = yaml.NewDecoder(configFile).Decode(&tmpl.Config)
-	if err != nil {
-		return nil, fmt.Errorf("decode config file: %w", err)
+	if !missingKeyModes[tmpl.Config.MissingKey] {
+		return nil, fmt.Errorf("invalid missingKey %q: must be default, zero or error", tmpl.Config.MissingKey)
 	}
 
 	// Load the JSON schema
-	schemaFile, err := l.root.Open(schemaPath)
+	schemaContent, err := l.schemaDocument(lineage)
 	if err != nil {
-		return nil, fmt.Errorf("open schema file: %w", err)
-	}
-	defer schemaFile.Close()
-	schemaContent, err := io.ReadAll(schemaFile)
-	if err != nil {
-		return nil, fmt.Errorf("read schema file: %w", err)
+		return nil, err
 	}
 	tmpl.Schema, err = l.schema.Compile(schemaContent)
 	if err != nil {
@@ -103,16 +169,43 @@ func (l *fsLoader) Load(name string) (*Template, error) {
 	}
 	defer fh.Close()
 	tmpl.ReadFrom(fh)
+	for _, n := range lineage[1:] {
+		p := path.Join(n, "template.html")
+		content, err := fs.ReadFile(l.root, p)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("read template file: %w", err)
+		}
+		tmpl.Overrides = append(tmpl.Overrides, Partial{Name: p, Text: string(content)})
+	}
 
-	// Load assets if they exist
-	if stat, err := fs.Stat(l.root, assetsPath); err == nil && stat.IsDir() {
-		tmpl.Assets, err = l.root.Sub(assetsPath)
-		if err != nil {
-			return nil, fmt.Errorf("load assets: %w", err)
+	tmpl.Partials, err = l.loadPartials()
+	if err != nil {
+		return nil, err
+	}
+
+	// Load assets if they exist, those of the extending templates hiding
+	// their base's
+	var assets layeredFS
+	for _, n := range slices.Backward(lineage) {
+		assetsPath := path.Join(n, "assets")
+		if stat, err := fs.Stat(l.root, assetsPath); err == nil && stat.IsDir() {
+			layer, err := l.root.Sub(assetsPath)
+			if err != nil {
+				return nil, fmt.Errorf("load assets: %w", err)
+			}
+			assets = append(assets, layer)
 		}
 	}
+	if len(assets) == 1 {
+		tmpl.Assets = assets[0]
+	} else if len(assets) > 1 {
+		tmpl.Assets = assets
+	}
 
-	// Load locales if they exist
+	// Load locales if they exist. Translations of the extending templates
+	// override those of their base.
 	if tmpl.Config.Locale != nil {
 		localeOpts := make([]func(*i18n.I18n), 1, 3)
 		localeOpts[0] = i18n.WithUnmarshaler(yaml.Unmarshal)
@@ -124,7 +217,11 @@ func (l *fsLoader) Load(name string) (*Template, error) {
 			localeOpts = append(localeOpts, i18n.WithLocales(tmpl.Config.Locale.Locales...))
 		}
 		tmpl.I18n = i18n.NewBundle(localeOpts...)
-		err = tmpl.I18n.LoadFS(l.root, localesGlob)
+		globs := make([]string, len(lineage))
+		for i, n := range lineage {
+			globs[i] = path.Join(n, "locales", "*.yaml")
+		}
+		err = tmpl.I18n.LoadFS(l.root, globs...)
 		if err != nil {
 			return nil, fmt.Errorf("load locales: %w", err)
 		}
@@ -143,5 +240,66 @@ func (l *fsLoader) Load(name string) (*Template, error) {
 		}
 	}
 
+	// Load the named examples if they exist
+	examplePaths, err := fs.Glob(l.root, examplesGlob)
+	if err != nil {
+		return nil, fmt.Errorf("find examples: %w", err)
+	}
+	for _, p := range examplePaths {
+		example, err := l.loadExample(p)
+		if err != nil {
+			return nil, err
+		}
+		if tmpl.Examples == nil {
+			tmpl.Examples = make(map[string]map[string]any)
+		}
+		tmpl.Examples[strings.TrimSuffix(path.Base(p), ".json")] = example
+	}
+
 	return tmpl, nil
 }
+
+// require returns ErrTemplateNotFound if the file doesn't exist
+func (l *fsLoader) require(p string) error {
+	_, err := fs.Stat(l.root, p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: missing %s", ErrTemplateNotFound, p)
+	} else if err != nil {
+		return fmt.Errorf("stat file: %w", err)
+	}
+	return nil
+}
+
+// loadPartials loads the shared templates from sharedDirs
+func (l *fsLoader) loadPartials() ([]Partial, error) {
+	var partials []Partial
+	for _, dir := range sharedDirs {
+		paths, err := fs.Glob(l.root, path.Join(dir, "*.html"))
+		if err != nil {
+			return nil, fmt.Errorf("find partials: %w", err)
+		}
+		for _, p := range paths {
+			data, err := fs.ReadFile(l.root, p)
+			if err != nil {
+				return nil, fmt.Errorf("read partial %s: %w", p, err)
+			}
+			partials = append(partials, Partial{Name: p, Text: string(data)})
+		}
+	}
+	return partials, nil
+}
+
+// loadExample loads a named example
+func (l *fsLoader) loadExample(p string) (map[string]any, error) {
+	fd, err := l.root.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("open example %s: %w", p, err)
+	}
+	defer fd.Close()
+
+	var example map[string]any
+	if err := json.NewDecoder(fd).Decode(&example); err != nil {
+		return nil, fmt.Errorf("decode example %s: %w", p, err)
+	}
+	return example, nil
+}
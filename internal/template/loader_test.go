@@ -1,10 +1,12 @@
 package template_test
 
 import (
+	"bytes"
 	"encoding/json"
 	"io/fs"
 	"testing"
 	"testing/fstest"
+	"time"
 
 	"github.com/sehrgutesoftware/httpdf/internal/template"
 	"github.com/stretchr/testify/assert"
@@ -401,4 +403,320 @@ locale:
 		assert.Equal(t, float64(42), tmpl.Example["count"]) // JSON numbers are float64
 		assert.Equal(t, true, tmpl.Example["enabled"])
 	})
+
+	t.Run("it_loads_named_examples", func(t *testing.T) {
+		mockFS := fstest.MapFS{
+			"with-examples/template.html": &fstest.MapFile{Data: []byte(`{{.name}}`)},
+			"with-examples/config.yaml": &fstest.MapFile{Data: []byte(`page:
+  width: 210
+  height: 297`)},
+			"with-examples/schema.json":                &fstest.MapFile{Data: []byte(`{"type": "object", "properties": {"name": {"type": "string"}}}`)},
+			"with-examples/examples/long-address.json": &fstest.MapFile{Data: []byte(`{"name": "Jane Doe"}`)},
+			"with-examples/examples/rtl-name.json":     &fstest.MapFile{Data: []byte(`{"name": "نور"}`)},
+		}
+
+		subFS, err := fs.Sub(mockFS, ".")
+		require.NoError(t, err)
+		loader := template.NewFSLoader(subFS.(fs.SubFS))
+
+		tmpl, err := loader.Load("with-examples")
+
+		require.NoError(t, err)
+		assert.Equal(t, map[string]map[string]any{
+			"long-address": {"name": "Jane Doe"},
+			"rtl-name":     {"name": "نور"},
+		}, tmpl.Examples)
+	})
+
+	t.Run("it_returns_error_when_a_named_example_does_not_match_the_schema", func(t *testing.T) {
+		mockFS := fstest.MapFS{
+			"invalid-example/template.html": &fstest.MapFile{Data: []byte(`{{.name}}`)},
+			"invalid-example/config.yaml": &fstest.MapFile{Data: []byte(`page:
+  width: 210
+  height: 297`)},
+			"invalid-example/schema.json":         &fstest.MapFile{Data: []byte(`{"type": "object", "properties": {"name": {"type": "string"}}}`)},
+			"invalid-example/examples/empty.json": &fstest.MapFile{Data: []byte(`{"name": 42}`)},
+		}
+
+		subFS, err := fs.Sub(mockFS, ".")
+		require.NoError(t, err)
+		loader := template.NewFSLoader(subFS.(fs.SubFS))
+
+		_, err = loader.Load("invalid-example")
+
+		assert.ErrorIs(t, err, template.ErrInvalidExample)
+		assert.ErrorContains(t, err, "examples/empty.json")
+	})
+
+	t.Run("it_loads_wait_conditions", func(t *testing.T) {
+		mockFS := fstest.MapFS{
+			"with-wait/template.html": &fstest.MapFile{
+				Data: []byte(`<html><body>Test</body></html>`),
+			},
+			"with-wait/config.yaml": &fstest.MapFile{
+				Data: []byte(`page:
+  width: 210
+  height: 297
+wait:
+  - selector: "#chart svg"
+    timeout: 5s
+  - expression: window.httpdfReady === true
+  - fonts: true
+  - networkIdle: true
+  - delay: 200ms`),
+			},
+			"with-wait/schema.json": &fstest.MapFile{
+				Data: []byte(`{"type": "object"}`),
+			},
+		}
+
+		subFS, err := fs.Sub(mockFS, ".")
+		require.NoError(t, err)
+		loader := template.NewFSLoader(subFS.(fs.SubFS))
+
+		tmpl, err := loader.Load("with-wait")
+
+		require.NoError(t, err)
+		assert.Equal(t, []template.WaitConfig{
+			{Selector: "#chart svg", Timeout: 5 * time.Second},
+			{Expression: "window.httpdfReady === true"},
+			{Fonts: true},
+			{NetworkIdle: true},
+			{Delay: 200 * time.Millisecond},
+		}, tmpl.Config.Wait)
+	})
+}
+
+func TestFSLoader_List(t *testing.T) {
+	t.Run("it_lists_the_directories_containing_a_template", func(t *testing.T) {
+		loader := template.NewFSLoader(fstest.MapFS{
+			"invoice/template.html": &fstest.MapFile{Data: []byte(`invoice`)},
+			"letter/template.html":  &fstest.MapFile{Data: []byte(`letter`)},
+			"fonts/font.woff2":      &fstest.MapFile{Data: []byte(`font`)},
+			"README.md":             &fstest.MapFile{Data: []byte(`readme`)},
+		})
+
+		names, err := loader.List()
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"invoice", "letter"}, names)
+	})
+}
+
+func TestFSLoader_Partials(t *testing.T) {
+	shared := func(files map[string]string) fstest.MapFS {
+		fsys := fstest.MapFS{
+			"letter/config.yaml": &fstest.MapFile{Data: []byte("page:\n  width: 210\n  height: 297\n")},
+			"letter/schema.json": &fstest.MapFile{Data: []byte(`{"type": "object"}`)},
+			"_layouts/page.html": &fstest.MapFile{Data: []byte(
+				`{{ define "page" }}<main>{{ template "letterhead" . }}{{ block "content" . }}empty{{ end }}</main>{{ end }}`,
+			)},
+			"_partials/letterhead.html": &fstest.MapFile{Data: []byte(`{{ define "letterhead" }}<header>ACME</header>{{ end }}`)},
+		}
+		for name, data := range files {
+			fsys[name] = &fstest.MapFile{Data: []byte(data)}
+		}
+		return fsys
+	}
+	render := func(t *testing.T, fsys fstest.MapFS) (string, error) {
+		t.Helper()
+		tmpl, err := template.NewFSLoader(fsys).Load("letter")
+		require.NoError(t, err)
+		var out bytes.Buffer
+		err = tmpl.Render(map[string]any{"name": "World"}, "/assets", "en", &out)
+		return out.String(), err
+	}
+
+	t.Run("it_makes_shared_layouts_and_partials_available", func(t *testing.T) {
+		out, err := render(t, shared(map[string]string{
+			"letter/template.html": `{{ template "page" . }}{{ define "content" }}Hello {{ .name }}{{ end }}`,
+		}))
+
+		require.NoError(t, err)
+		assert.Equal(t, "<main><header>ACME</header>Hello World</main>", out)
+	})
+
+	t.Run("it_prefers_definitions_of_the_template", func(t *testing.T) {
+		out, err := render(t, shared(map[string]string{
+			"letter/template.html": `{{ template "page" . }}{{ define "letterhead" }}<header>Local</header>{{ end }}`,
+		}))
+
+		require.NoError(t, err)
+		assert.Equal(t, "<main><header>Local</header>empty</main>", out)
+	})
+
+	t.Run("it_fails_if_partials_define_the_same_name", func(t *testing.T) {
+		_, err := render(t, shared(map[string]string{
+			"letter/template.html":   `{{ template "letterhead" . }}`,
+			"_partials/address.html": `{{ define "letterhead" }}Other{{ end }}`,
+		}))
+
+		assert.ErrorContains(t, err, `_partials/letterhead.html: template "letterhead" is already defined in _partials/address.html`)
+	})
+
+	t.Run("it_reports_syntax_errors_with_the_partial", func(t *testing.T) {
+		_, err := render(t, shared(map[string]string{
+			"letter/template.html":  `{{ template "page" . }}`,
+			"_partials/footer.html": `{{ define "footer" }}{{ .name {{ end }}`,
+		}))
+
+		assert.ErrorContains(t, err, "_partials/footer.html:1")
+	})
+
+	t.Run("it_does_not_list_the_shared_directories", func(t *testing.T) {
+		names, err := template.NewFSLoader(shared(map[string]string{"letter/template.html": `letter`})).List()
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"letter"}, names)
+	})
+}
+
+func TestFSLoader_Extends(t *testing.T) {
+	extends := func(files map[string]string) fstest.MapFS {
+		fsys := fstest.MapFS{
+			"base-letter/template.html": &fstest.MapFile{Data: []byte(
+				`<link href="{{ asset "style.css" }}"><img src="{{ asset "logo.png" }}">{{ tr "greeting" }} {{ .recipient }}. {{ block "body" . }}Base{{ end }}`,
+			)},
+			"base-letter/config.yaml": &fstest.MapFile{Data: []byte(
+				"page:\n  width: 210\n  height: 297\nlocale:\n  locales: [en, de]\n  default: en\nstrict: true\n",
+			)},
+			"base-letter/schema.json": &fstest.MapFile{Data: []byte(
+				`{"type": "object", "$defs": {"name": {"type": "string"}}, "properties": {"recipient": {"$ref": "#/$defs/name"}}, "required": ["recipient"]}`,
+			)},
+			"base-letter/assets/style.css": &fstest.MapFile{Data: []byte(`base`)},
+			"base-letter/assets/logo.png":  &fstest.MapFile{Data: []byte(`logo`)},
+			"base-letter/locales/en.yaml":  &fstest.MapFile{Data: []byte("greeting: Hello\nclosing: Regards\n")},
+			"base-letter/locales/de.yaml":  &fstest.MapFile{Data: []byte("greeting: Hallo\nclosing: Grüße\n")},
+			"reminder/config.yaml":         &fstest.MapFile{Data: []byte("extends: base-letter\npage:\n  height: 148\n")},
+			"reminder/template.html":       &fstest.MapFile{Data: []byte(`{{ define "body" }}Pay {{ .amount }}. {{ tr "closing" }}{{ end }}`)},
+			"reminder/schema.json": &fstest.MapFile{Data: []byte(
+				`{"type": "object", "properties": {"amount": {"type": "number"}}, "required": ["amount"]}`,
+			)},
+			"reminder/assets/style.css": &fstest.MapFile{Data: []byte(`reminder`)},
+			"reminder/locales/en.yaml":  &fstest.MapFile{Data: []byte("closing: Please pay\n")},
+		}
+		for name, data := range files {
+			if data == "" {
+				delete(fsys, name)
+				continue
+			}
+			fsys[name] = &fstest.MapFile{Data: []byte(data)}
+		}
+		return fsys
+	}
+	render := func(t *testing.T, tmpl *template.Template, values map[string]any, locale string) string {
+		t.Helper()
+		var out bytes.Buffer
+		require.NoError(t, tmpl.Render(values, "/assets", locale, &out))
+		return out.String()
+	}
+
+	t.Run("it_inherits_the_config_of_the_base", func(t *testing.T) {
+		tmpl, err := template.NewFSLoader(extends(nil)).Load("reminder")
+
+		require.NoError(t, err)
+		assert.Equal(t, "base-letter", tmpl.Config.Extends)
+		assert.Equal(t, 210.0, tmpl.Config.Page.Width)
+		assert.Equal(t, 148.0, tmpl.Config.Page.Height)
+		assert.True(t, tmpl.Config.Strict)
+		require.NotNil(t, tmpl.Config.Locale)
+		assert.Equal(t, []string{"en", "de"}, tmpl.Config.Locale.Locales)
+	})
+
+	t.Run("it_merges_nested_config_of_the_base", func(t *testing.T) {
+		tmpl, err := template.NewFSLoader(extends(map[string]string{
+			"base-letter/config.yaml": "page:\n  width: 210\n  height: 297\nwatermarks:\n  draft:\n    text: DRAFT\n    opacity: 0.3\n  copy:\n    text: COPY\n",
+			"reminder/config.yaml":    "extends: base-letter\nwatermarks:\n  draft:\n    opacity: 0.5\n",
+		})).Load("reminder")
+
+		require.NoError(t, err)
+		require.Contains(t, tmpl.Config.Watermarks, "draft")
+		assert.Equal(t, "DRAFT", tmpl.Config.Watermarks["draft"].Text)
+		assert.Equal(t, 0.5, tmpl.Config.Watermarks["draft"].Opacity)
+		assert.Equal(t, "COPY", tmpl.Config.Watermarks["copy"].Text)
+	})
+
+	t.Run("it_overrides_the_blocks_and_translations_of_the_base", func(t *testing.T) {
+		tmpl, err := template.NewFSLoader(extends(nil)).Load("reminder")
+		require.NoError(t, err)
+
+		values := map[string]any{"recipient": "Jane", "amount": 10}
+		assert.Equal(t, `<link href="/assets/style.css"><img src="/assets/logo.png">Hello Jane. Pay 10. Please pay`, render(t, tmpl, values, "en"))
+		assert.Equal(t, `<link href="/assets/style.css"><img src="/assets/logo.png">Hallo Jane. Pay 10. Grüße`, render(t, tmpl, values, "de"))
+	})
+
+	t.Run("it_merges_the_schemas_with_all_of", func(t *testing.T) {
+		tmpl, err := template.NewFSLoader(extends(nil)).Load("reminder")
+		require.NoError(t, err)
+
+		assert.True(t, tmpl.Schema.Validate(map[string]any{"recipient": "Jane", "amount": 10}).Valid)
+		assert.False(t, tmpl.Schema.Validate(map[string]any{"amount": 10}).Valid)
+		assert.False(t, tmpl.Schema.Validate(map[string]any{"recipient": "Jane"}).Valid)
+		assert.False(t, tmpl.Schema.Validate(map[string]any{"recipient": 42, "amount": 10}).Valid)
+	})
+
+	t.Run("it_layers_the_assets_over_those_of_the_base", func(t *testing.T) {
+		tmpl, err := template.NewFSLoader(extends(nil)).Load("reminder")
+		require.NoError(t, err)
+
+		style, err := fs.ReadFile(tmpl.Assets, "style.css")
+		require.NoError(t, err)
+		assert.Equal(t, "reminder", string(style))
+		logo, err := fs.ReadFile(tmpl.Assets, "logo.png")
+		require.NoError(t, err)
+		assert.Equal(t, "logo", string(logo))
+		_, err = fs.ReadFile(tmpl.Assets, "missing.png")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("it_inherits_the_template_and_schema_if_omitted", func(t *testing.T) {
+		tmpl, err := template.NewFSLoader(extends(map[string]string{
+			"reminder/template.html": "",
+			"reminder/schema.json":   "",
+		})).Load("reminder")
+		require.NoError(t, err)
+
+		assert.True(t, tmpl.Schema.Validate(map[string]any{"recipient": "Jane"}).Valid)
+		assert.Contains(t, render(t, tmpl, map[string]any{"recipient": "Jane"}, "en"), "Hello Jane. Base")
+	})
+
+	t.Run("it_extends_templates_extending_others", func(t *testing.T) {
+		tmpl, err := template.NewFSLoader(extends(map[string]string{
+			"final/config.yaml":   "extends: reminder\n",
+			"final/template.html": `{{ define "body" }}Last chance: {{ .amount }}{{ end }}`,
+			"final/schema.json":   `{"type": "object", "properties": {"due": {"type": "string"}}, "required": ["due"]}`,
+		})).Load("final")
+		require.NoError(t, err)
+
+		assert.False(t, tmpl.Schema.Validate(map[string]any{"recipient": "Jane", "amount": 10}).Valid)
+		assert.False(t, tmpl.Schema.Validate(map[string]any{"amount": 10, "due": "tomorrow"}).Valid)
+		assert.True(t, tmpl.Schema.Validate(map[string]any{"recipient": "Jane", "amount": 10, "due": "tomorrow"}).Valid)
+		assert.Equal(t, 148.0, tmpl.Config.Page.Height)
+		assert.Contains(t, render(t, tmpl, map[string]any{"recipient": "Jane", "amount": 10}, "en"), "Hello Jane. Last chance: 10")
+	})
+
+	t.Run("it_rejects_cycles", func(t *testing.T) {
+		_, err := template.NewFSLoader(extends(map[string]string{
+			"base-letter/config.yaml": "extends: reminder\n",
+		})).Load("reminder")
+
+		assert.ErrorIs(t, err, template.ErrInvalidExtends)
+		assert.ErrorContains(t, err, "reminder -> base-letter -> reminder")
+	})
+
+	t.Run("it_rejects_unknown_base_templates", func(t *testing.T) {
+		_, err := template.NewFSLoader(extends(map[string]string{
+			"reminder/config.yaml": "extends: missing\n",
+		})).Load("reminder")
+
+		assert.ErrorIs(t, err, template.ErrInvalidExtends)
+		assert.NotErrorIs(t, err, template.ErrTemplateNotFound)
+	})
+
+	t.Run("it_lists_templates_without_template_html", func(t *testing.T) {
+		names, err := template.NewFSLoader(extends(map[string]string{"reminder/template.html": ""})).List()
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"base-letter", "reminder"}, names)
+	})
 }
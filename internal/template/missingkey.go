@@ -0,0 +1,144 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// ErrMissingKey is returned if the template references a value that doesn't
+// exist and missingKey is set to error
+var ErrMissingKey = errors.New("missing key")
+
+// missingKeyModes are the valid values of Config.MissingKey
+var missingKeyModes = map[string]bool{"": true, "default": true, "zero": true, "error": true}
+
+// MissingKeyError describes a reference to a missing value. It wraps
+// ErrMissingKey.
+type MissingKeyError struct {
+	// File is the file containing the reference relative to the templates
+	// directory, e.g. _partials/letterhead.html. It's empty for templates
+	// and expressions not loaded from files.
+	File string
+	// Line and Column locate the reference in the file, starting at 1
+	Line   int
+	Column int
+	// Field is the referencing expression, e.g. .customer.name
+	Field string
+	// Key is the key that doesn't exist, e.g. customer
+	Key string
+}
+
+func (e *MissingKeyError) Error() string {
+	msg := fmt.Sprintf("%d:%d: %s: %s %q", e.Line, e.Column, e.Field, ErrMissingKey, e.Key)
+	if e.File != "" {
+		return e.File + ":" + msg
+	}
+	return msg
+}
+
+func (e *MissingKeyError) Unwrap() error {
+	return ErrMissingKey
+}
+
+// missingKeyPattern matches the message of text/template's missing key
+// errors, which don't expose the position otherwise. It's checked against the
+// standard library by the tests.
+var missingKeyPattern = regexp.MustCompile(`^template: ([^:]+):(\d+):(\d+): executing ".*?" at <(.*)>: map has no entry for key "(.*)"$`)
+
+// zeroMissing replaces missing values with the empty string
+func zeroMissing(v any) any {
+	if v == nil {
+		return ""
+	}
+	return v
+}
+
+// zeroMissingCmd is the command appended to printing actions in zero mode
+var zeroMissingCmd = func() *parse.CommandNode {
+	trees, err := parse.Parse("zero", "{{ . | _zeroMissing }}", "", "", map[string]any{"_zeroMissing": zeroMissing})
+	if err != nil {
+		panic(err)
+	}
+	return trees["zero"].Root.Nodes[0].(*parse.ActionNode).Pipe.Cmds[1]
+}()
+
+// zeroMissingActions pipes the output of all actions below node, which don't
+// declare or assign variables, through _zeroMissing
+func zeroMissingActions(node parse.Node) {
+	walk(node, func(n parse.Node) {
+		if action, ok := n.(*parse.ActionNode); ok && len(action.Pipe.Decl) == 0 {
+			action.Pipe.Cmds = append(action.Pipe.Cmds, zeroMissingCmd)
+		}
+	})
+}
+
+// missingKeyError converts missing key errors of executing parsed to a
+// MissingKeyError. sources are the parsed texts by name. Missing key errors
+// whose message isn't recognized are wrapped in ErrMissingKey without
+// position. Other errors are returned unchanged.
+func missingKeyError(parsed *template.Template, sources map[string]string, err error) error {
+	var execErr template.ExecError
+	if !errors.As(err, &execErr) {
+		return err
+	}
+	match := missingKeyPattern.FindStringSubmatch(execErr.Error())
+	if match == nil {
+		if strings.Contains(execErr.Error(), "no entry for key") {
+			return fmt.Errorf("%w: %w", ErrMissingKey, err)
+		}
+		return err
+	}
+
+	source := match[1]
+	line, _ := strconv.Atoi(match[2])
+	column, _ := strconv.Atoi(match[3])
+	e := &MissingKeyError{Line: line, Column: column + 1, Field: match[4], Key: match[5]}
+	if source != mainName && source != exprName {
+		e.File = source
+	}
+	text := sources[source]
+
+	// The expression in the message is truncated if it's long, so it's
+	// looked up in the parse tree instead. The position of fields with
+	// several segments is that of the second one, so the column is moved
+	// to the start of the expression.
+	start := 0
+	for i := 1; i < line; i++ {
+		next := strings.IndexByte(text[start:], '\n')
+		if next < 0 {
+			return e
+		}
+		start += next + 1
+	}
+	found := false
+	for _, tmpl := range parsed.Templates() {
+		if tmpl.Tree == nil || tmpl.Tree.ParseName != source {
+			continue
+		}
+		walk(tmpl.Tree.Root, func(node parse.Node) {
+			if found || int(node.Position()) != start+column {
+				return
+			}
+			switch n := node.(type) {
+			case *parse.FieldNode:
+				if len(n.Ident) > 1 {
+					e.Column -= len(n.Ident[0]) + 1
+				}
+			case *parse.VariableNode:
+				if len(n.Ident) > 1 {
+					e.Column -= len(n.Ident[0])
+				}
+			case *parse.ChainNode:
+			default:
+				return
+			}
+			e.Field, found = node.String(), true
+		})
+	}
+	return e
+}
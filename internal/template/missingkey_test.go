@@ -0,0 +1,35 @@
+package template
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMissingKeyError(t *testing.T) {
+	t.Run("it_matches_the_errors_of_text_template", func(t *testing.T) {
+		parsed := template.Must(template.New("main").Option("missingkey=error").Parse("{{ .customer.email }}"))
+
+		err := parsed.Execute(io.Discard, map[string]any{"customer": map[string]any{}})
+
+		var execErr template.ExecError
+		require.True(t, errors.As(err, &execErr))
+		assert.Regexp(t, missingKeyPattern, execErr.Error())
+	})
+
+	t.Run("it_wraps_unrecognized_missing_key_errors", func(t *testing.T) {
+		parsed := template.Must(template.New("main").Parse("{{ .customer.email }}"))
+		execErr := template.ExecError{Name: "main", Err: errors.New(`template: main: no entry for key "email" in map`)}
+
+		err := missingKeyError(parsed, map[string]string{"main": "{{ .customer.email }}"}, execErr)
+
+		assert.ErrorIs(t, err, ErrMissingKey)
+		assert.ErrorContains(t, err, `no entry for key "email"`)
+		var missing *MissingKeyError
+		assert.False(t, errors.As(err, &missing))
+	})
+}
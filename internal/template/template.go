@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"slices"
 	"text/template"
+	"time"
 
 	"github.com/kaptinlin/go-i18n"
 	"github.com/kaptinlin/jsonschema"
@@ -13,6 +15,10 @@ import (
 
 // Config represents the configuration of a template
 type Config struct {
+	// Extends names a base template, whose config, assets, locales and
+	// schema the template inherits, and whose blocks it can override
+	Extends string `yaml:"extends"`
+
 	Page struct {
 		Width  float64 `yaml:"width"`
 		Height float64 `yaml:"height"`
@@ -26,6 +32,95 @@ type Config struct {
 		GenerateTaggedPDF       bool `yaml:"generateTaggedPDF"`
 		GenerateDocumentOutline bool `yaml:"generateDocumentOutline"`
 	} `yaml:"pdf"`
+	Signature  *SignatureConfig  `yaml:"signature"`
+	Encryption *EncryptionConfig `yaml:"encryption"`
+	// Watermarks are named overlays, which can be selected per render
+	Watermarks map[string]WatermarkConfig `yaml:"watermarks"`
+	// Renderer selects a renderer registered by name, e.g. firefox. If
+	// empty, the server's default renderer is used.
+	Renderer string `yaml:"renderer"`
+	// Wait lists conditions to wait for before the page is printed, in order
+	Wait    []WaitConfig `yaml:"wait"`
+	Network struct {
+		// Allow lists hosts and URL patterns the page may request in
+		// addition to the template's assets
+		Allow []string `yaml:"allow"`
+	} `yaml:"network"`
+	// Strict fails renders if the page throws an exception or fails to load
+	// a resource
+	Strict bool `yaml:"strict"`
+	// ApplyDefaults fills in the defaults declared by the schema before the
+	// template is rendered
+	ApplyDefaults bool `yaml:"applyDefaults"`
+	// MissingKey controls references to values that don't exist: default
+	// prints <no value>, zero prints nothing and error fails the render
+	MissingKey string `yaml:"missingKey"`
+	// PostProcessors selects the post-processors applied to rendered PDFs,
+	// in order. If nil, all available post-processors are applied.
+	PostProcessors []string `yaml:"postProcessors"`
+}
+
+// WaitConfig is a condition to wait for before printing the page. Exactly one
+// of the conditions must be set per entry.
+type WaitConfig struct {
+	Selector    string        `yaml:"selector"`
+	Expression  string        `yaml:"expression"`
+	Fonts       bool          `yaml:"fonts"`
+	NetworkIdle bool          `yaml:"networkIdle"`
+	Delay       time.Duration `yaml:"delay"`
+	Timeout     time.Duration `yaml:"timeout"`
+}
+
+// SignatureConfig configures the digital signature applied to rendered PDFs.
+// Credentials are read either from a PKCS#12 file or from a pair of PEM files.
+type SignatureConfig struct {
+	PKCS12      string `yaml:"pkcs12"`
+	Certificate string `yaml:"certificate"`
+	Key         string `yaml:"key"`
+	// PasswordEnv is the name of the environment variable holding the
+	// password of the PKCS#12 file
+	PasswordEnv string `yaml:"passwordEnv"`
+	Name        string `yaml:"name"`
+	Reason      string `yaml:"reason"`
+	Location    string `yaml:"location"`
+	ContactInfo string `yaml:"contactInfo"`
+	// Appearance makes the signature visible; position and size in mm,
+	// measured from the top left corner of the page
+	Appearance *struct {
+		Page   int     `yaml:"page"`
+		X      float64 `yaml:"x"`
+		Y      float64 `yaml:"y"`
+		Width  float64 `yaml:"width"`
+		Height float64 `yaml:"height"`
+	} `yaml:"appearance"`
+}
+
+// EncryptionConfig configures password protection of rendered PDFs. The
+// passwords are template expressions, evaluated against the render values.
+type EncryptionConfig struct {
+	UserPassword  string   `yaml:"userPassword"`
+	OwnerPassword string   `yaml:"ownerPassword"`
+	Permissions   []string `yaml:"permissions"`
+}
+
+// WatermarkConfig configures a text or image overlay for rendered PDFs
+type WatermarkConfig struct {
+	// Text is a template expression, evaluated against the render values
+	Text string `yaml:"text"`
+	// Image is the path of an image within the template's assets
+	Image string `yaml:"image"`
+	// Always applies the watermark to every render, not only if requested
+	Always   bool     `yaml:"always"`
+	OnTop    bool     `yaml:"onTop"`
+	Rotation *float64 `yaml:"rotation"`
+	Opacity  float64  `yaml:"opacity"`
+	Position string   `yaml:"position"`
+	OffsetX  float64  `yaml:"offsetX"`
+	OffsetY  float64  `yaml:"offsetY"`
+	Scale    float64  `yaml:"scale"`
+	FontSize int      `yaml:"fontSize"`
+	Color    string   `yaml:"color"`
+	Pages    []string `yaml:"pages"`
 }
 
 // Template represents a template
@@ -35,26 +130,150 @@ type Template struct {
 	Schema  *jsonschema.Schema
 	Assets  fs.FS
 	Example map[string]any
-	I18n    *i18n.I18n
+	// Examples are named example data sets, e.g. for edge cases
+	Examples map[string]map[string]any
+	I18n     *i18n.I18n
+	// Partials are the shared layouts and partials, whose definitions are
+	// available to the template unless it defines them itself
+	Partials []Partial
+	// Bases are the names of the templates the template extends, directly
+	// or indirectly, starting with the base-most one
+	Bases []string
+	// Overrides are the template.html files of the templates extending the
+	// base template, from the base to the extending template. Their
+	// definitions replace those of the base, the rest of their content is
+	// ignored.
+	Overrides []Partial
+
+	// file is the path of template.html, used to locate errors
+	file string
+}
+
+// Partial is a shared template file, e.g. _partials/letterhead.html
+type Partial struct {
+	Name string
+	Text string
 }
 
+// Names of the parsed texts not loaded from files
+const (
+	mainName = "main"
+	exprName = "expr"
+)
+
 // Render the template with the given values to the output
 func (t *Template) Render(values map[string]any, assetsPrefix string, locale string, out io.Writer) error {
-	funcs := templateFuncs(assetsPrefix)
-	i18nTemplateFuncs(funcs, t.I18n, locale)
-	envTemplateFuncs(funcs, t.Config.ExposedEnvVars)
-	barcodeTemplateFuncs(funcs)
-
-	renderer := template.New("main").Funcs(funcs)
-	parsed, err := renderer.Parse(t.String())
+	parsed, err := t.parseTemplate(t.funcs(assetsPrefix, locale))
 	if err != nil {
 		return fmt.Errorf("parse template: %w", err)
 	}
 
 	err = parsed.Execute(out, values)
 	if err != nil {
-		return fmt.Errorf("execute template: %w", err)
+		return fmt.Errorf("execute template: %w", missingKeyError(parsed, t.sources(), err))
 	}
 
 	return nil
 }
+
+// Expand evaluates a template expression, such as a configuration value, with
+// the given values. The same functions as in the template itself are available.
+func (t *Template) Expand(expr string, values map[string]any, locale string) (string, error) {
+	parsed, err := t.parse(exprName, expr, nil, t.funcs("", locale))
+	if err != nil {
+		return "", fmt.Errorf("parse expression: %w", err)
+	}
+
+	var out bytes.Buffer
+	if err := parsed.Execute(&out, values); err != nil {
+		return "", fmt.Errorf("execute expression: %w", missingKeyError(parsed, map[string]string{exprName: expr}, err))
+	}
+
+	return out.String(), nil
+}
+
+// parseTemplate parses the template along with its partials and overrides
+func (t *Template) parseTemplate(funcs template.FuncMap) (*template.Template, error) {
+	name := mainName
+	if t.file != "" {
+		name = t.file
+	}
+	return t.parse(name, t.String(), t.Overrides, funcs)
+}
+
+// sources returns the texts of the template, its partials and overrides by
+// the names they are parsed with
+func (t *Template) sources() map[string]string {
+	sources := map[string]string{mainName: t.String()}
+	if t.file != "" {
+		sources[t.file] = t.String()
+	}
+	for _, p := range slices.Concat(t.Partials, t.Overrides) {
+		sources[p.Name] = p.Text
+	}
+	return sources
+}
+
+// parse parses the text with the functions and the missing key handling of
+// the template. The definitions of the partials are added first, so that the
+// text can override them, and those of the overrides last.
+func (t *Template) parse(name, text string, overrides []Partial, funcs template.FuncMap) (*template.Template, error) {
+	mode := t.Config.MissingKey
+	if mode == "zero" {
+		funcs["_zeroMissing"] = zeroMissing
+	}
+	parsed := template.New(name).Funcs(funcs)
+
+	definedBy := make(map[string]string)
+	for _, p := range t.Partials {
+		set, err := template.New(p.Name).Funcs(funcs).Parse(p.Text)
+		if err != nil {
+			return nil, err
+		}
+		for _, def := range set.Templates() {
+			if def.Name() == p.Name {
+				continue
+			}
+			if other, ok := definedBy[def.Name()]; ok {
+				return nil, fmt.Errorf("%s: template %q is already defined in %s", p.Name, def.Name(), other)
+			}
+			definedBy[def.Name()] = p.Name
+			if _, err := parsed.AddParseTree(def.Name(), def.Tree); err != nil {
+				return nil, fmt.Errorf("%s: %w", p.Name, err)
+			}
+		}
+	}
+
+	parsed, err := parsed.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range overrides {
+		if _, err := parsed.New(o.Name).Parse(o.Text); err != nil {
+			return nil, err
+		}
+	}
+
+	switch mode {
+	case "error":
+		parsed.Option("missingkey=error")
+	case "zero":
+		// missingkey=zero yields nil for maps of any, which is still
+		// printed as <no value>, so printed values are piped through
+		// _zeroMissing instead
+		for _, tmpl := range parsed.Templates() {
+			if tmpl.Tree != nil {
+				zeroMissingActions(tmpl.Tree.Root)
+			}
+		}
+	}
+	return parsed, nil
+}
+
+func (t *Template) funcs(assetsPrefix string, locale string) template.FuncMap {
+	funcs := templateFuncs(assetsPrefix)
+	i18nTemplateFuncs(funcs, t.I18n, locale)
+	envTemplateFuncs(funcs, t.Config.ExposedEnvVars)
+	barcodeTemplateFuncs(funcs)
+	return funcs
+}
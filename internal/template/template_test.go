@@ -2,6 +2,7 @@ package template_test
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"testing"
 
@@ -241,3 +242,109 @@ func TestRender(t *testing.T) {
 		assert.Equal(t, "Value: ", output.String())
 	})
 }
+
+func TestRender_MissingKey(t *testing.T) {
+	render := func(mode, text string, values map[string]any) (string, error) {
+		tmpl := &template.Template{}
+		tmpl.Config.MissingKey = mode
+		tmpl.WriteString(text)
+		var output bytes.Buffer
+		err := tmpl.Render(values, "/assets", "en", &output)
+		return output.String(), err
+	}
+	values := map[string]any{"customer": map[string]any{"name": "ACME"}}
+
+	t.Run("it_prints_no_value_by_default", func(t *testing.T) {
+		output, err := render("", "Hello {{ .customer.email }}", values)
+
+		require.NoError(t, err)
+		assert.Equal(t, "Hello <no value>", output)
+	})
+
+	t.Run("it_prints_nothing_in_zero_mode", func(t *testing.T) {
+		output, err := render("zero", "{{ .customer.name }} {{ .customer.email }}{{ $x := .missing }}{{ $x }}|{{ .customer.email | default \"-\" }}", values)
+
+		require.NoError(t, err)
+		assert.Equal(t, "ACME |-", output)
+	})
+
+	t.Run("it_leaves_printf_and_null_values_working_in_zero_mode", func(t *testing.T) {
+		text := `{{ printf "%v" .missing }}|{{ printf "%v" .null }}|{{ .null }}|{{ if .null }}set{{ else }}unset{{ end }}|{{ .null | default "-" }}|{{ toJson .null }}`
+		values := map[string]any{"null": nil}
+
+		output, err := render("zero", text, values)
+
+		require.NoError(t, err)
+		assert.Equal(t, "<nil>|<nil>||unset|-|null", output)
+	})
+
+	t.Run("it_fails_with_line_column_and_field_in_error_mode", func(t *testing.T) {
+		_, err := render("error", "<h1>Invoice</h1>\n<p>{{ .customer.name }}, {{ .customer.billingAddress.street }}</p>", values)
+
+		require.ErrorIs(t, err, template.ErrMissingKey)
+		var missing *template.MissingKeyError
+		require.True(t, errors.As(err, &missing))
+		assert.Equal(t, 2, missing.Line)
+		assert.Equal(t, 29, missing.Column)
+		assert.Equal(t, ".customer.billingAddress.street", missing.Field)
+		assert.Equal(t, "billingAddress", missing.Key)
+		assert.Contains(t, err.Error(), "2:29: .customer.billingAddress.street")
+	})
+
+	t.Run("it_reports_missing_keys_in_ranges_in_error_mode", func(t *testing.T) {
+		items := map[string]any{"items": []any{map[string]any{"name": "a"}}}
+		_, err := render("error", "{{ range $item := .items }}\n  {{ $item.price }}\n{{ end }}", items)
+
+		var missing *template.MissingKeyError
+		require.True(t, errors.As(err, &missing))
+		assert.Equal(t, 2, missing.Line)
+		assert.Equal(t, 6, missing.Column)
+		assert.Equal(t, "$item.price", missing.Field)
+	})
+}
+
+func TestExpand(t *testing.T) {
+	t.Run("it_expands_an_expression_with_values", func(t *testing.T) {
+		tmpl := &template.Template{}
+
+		result, err := tmpl.Expand("{{ .employee.id }}-{{ .employee.birthday | trunc 4 }}", map[string]any{
+			"employee": map[string]any{"id": "E42", "birthday": "1990-01-01"},
+		}, "en")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "E42-1990", result)
+	})
+
+	t.Run("it_returns_plain_strings_unchanged", func(t *testing.T) {
+		tmpl := &template.Template{}
+
+		result, err := tmpl.Expand("secret", map[string]any{}, "en")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "secret", result)
+	})
+
+	t.Run("it_only_exposes_configured_env_vars", func(t *testing.T) {
+		t.Setenv("EXPOSED_PASSWORD", "exposed")
+		t.Setenv("HIDDEN_PASSWORD", "hidden")
+		tmpl := &template.Template{
+			Config: template.Config{
+				ExposedEnvVars: []string{"EXPOSED_PASSWORD"},
+			},
+		}
+
+		result, err := tmpl.Expand(`{{ env "EXPOSED_PASSWORD" }}/{{ env "HIDDEN_PASSWORD" }}`, map[string]any{}, "en")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "exposed/", result)
+	})
+
+	t.Run("it_returns_an_error_for_invalid_expressions", func(t *testing.T) {
+		tmpl := &template.Template{}
+
+		_, err := tmpl.Expand("{{ .unclosed", map[string]any{}, "en")
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "parse expression")
+	})
+}
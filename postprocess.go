@@ -0,0 +1,229 @@
+package httpdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+
+	"github.com/sehrgutesoftware/httpdf/internal/pdf"
+	"github.com/sehrgutesoftware/httpdf/internal/template"
+)
+
+// PostProcessor transforms a rendered PDF document before it is written to
+// the response, e.g. to add metadata, encrypt or compress it.
+type PostProcessor interface {
+	// Name identifies the post-processor in the template's config
+	Name() string
+	// Process transforms the document. Post-processors that don't apply to
+	// the job return the document unchanged.
+	Process(ctx context.Context, job *Job, document []byte) ([]byte, error)
+}
+
+// watermarkProcessor applies the watermarks configured by the template or
+// requested for the job
+type watermarkProcessor struct{}
+
+func (p *watermarkProcessor) Name() string {
+	return "watermark"
+}
+
+func (p *watermarkProcessor) Process(ctx context.Context, job *Job, document []byte) ([]byte, error) {
+	watermarks, err := watermarks(job.Template, job.Locale, job.Values, job.Watermarks)
+	if err != nil {
+		return nil, err
+	}
+	if len(watermarks) == 0 {
+		return document, nil
+	}
+
+	out := &bytes.Buffer{}
+	if err := pdf.AddWatermarks(document, out, watermarks); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// encryptionProcessor encrypts the document if configured by the template or
+// requested for the job
+type encryptionProcessor struct{}
+
+func (p *encryptionProcessor) Name() string {
+	return "encrypt"
+}
+
+func (p *encryptionProcessor) Process(ctx context.Context, job *Job, document []byte) ([]byte, error) {
+	opts, err := encryptOpts(job.Template, job.Locale, job.Values, job.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		return document, nil
+	}
+
+	out := &bytes.Buffer{}
+	if err := pdf.Encrypt(document, out, *opts); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// signatureProcessor signs the document if configured by the template
+type signatureProcessor struct{}
+
+func (p *signatureProcessor) Name() string {
+	return "sign"
+}
+
+func (p *signatureProcessor) Process(ctx context.Context, job *Job, document []byte) ([]byte, error) {
+	if job.Template.Config.Signature == nil {
+		return document, nil
+	}
+
+	out := &bytes.Buffer{}
+	if err := sign(job.Template.Config.Signature, document, out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// watermarks resolves the watermarks to apply: all configured with always,
+// plus the requested ones.
+func watermarks(t *template.Template, locale string, v map[string]any, requested []string) ([]pdf.Watermark, error) {
+	var names []string
+	for name, c := range t.Config.Watermarks {
+		if c.Always {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range requested {
+		if name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	result := make([]pdf.Watermark, 0, len(names))
+	for _, name := range names {
+		c, ok := t.Config.Watermarks[name]
+		if !ok {
+			// Not configured by the template, use the name as text
+			result = append(result, pdf.Watermark{Text: name, Opacity: 0.3})
+			continue
+		}
+
+		wm := pdf.Watermark{
+			OnTop:    c.OnTop,
+			Rotation: c.Rotation,
+			Opacity:  c.Opacity,
+			Position: c.Position,
+			OffsetX:  c.OffsetX,
+			OffsetY:  c.OffsetY,
+			Scale:    c.Scale,
+			FontSize: c.FontSize,
+			Color:    c.Color,
+			Pages:    c.Pages,
+		}
+		if c.Image != "" {
+			if t.Assets == nil {
+				return nil, fmt.Errorf("%s: template has no assets", name)
+			}
+			img, err := fs.ReadFile(t.Assets, c.Image)
+			if err != nil {
+				return nil, fmt.Errorf("%s: read image: %w", name, err)
+			}
+			wm.Image = img
+		} else {
+			text, err := t.Expand(c.Text, v, locale)
+			if err != nil {
+				return nil, fmt.Errorf("%s: text: %w", name, err)
+			}
+			wm.Text = text
+		}
+		result = append(result, wm)
+	}
+
+	return result, nil
+}
+
+// encryptOpts resolves the encryption options from the template's config,
+// evaluating the password expressions, and the per-request override. It
+// returns nil if the document is not to be encrypted.
+func encryptOpts(t *template.Template, locale string, v map[string]any, override *Encryption) (*pdf.EncryptOpts, error) {
+	c := t.Config.Encryption
+	if c == nil && override == nil {
+		return nil, nil
+	}
+
+	var (
+		opts        pdf.EncryptOpts
+		permissions []string
+		err         error
+	)
+	if c != nil {
+		if opts.UserPassword, err = t.Expand(c.UserPassword, v, locale); err != nil {
+			return nil, fmt.Errorf("user password: %w", err)
+		}
+		if opts.OwnerPassword, err = t.Expand(c.OwnerPassword, v, locale); err != nil {
+			return nil, fmt.Errorf("owner password: %w", err)
+		}
+		permissions = c.Permissions
+	}
+	if override != nil {
+		if override.UserPassword != "" {
+			opts.UserPassword = override.UserPassword
+		}
+		if override.OwnerPassword != "" {
+			opts.OwnerPassword = override.OwnerPassword
+		}
+		if override.Permissions != nil {
+			permissions = override.Permissions
+		}
+	}
+	for _, p := range permissions {
+		opts.Permissions = append(opts.Permissions, pdf.Permission(p))
+	}
+
+	return &opts, nil
+}
+
+// sign applies the configured digital signature to the rendered document
+func sign(c *template.SignatureConfig, document []byte, w io.Writer) error {
+	var (
+		creds *pdf.Credentials
+		err   error
+	)
+	switch {
+	case c.PKCS12 != "":
+		creds, err = pdf.LoadPKCS12(c.PKCS12, os.Getenv(c.PasswordEnv))
+	case c.Certificate != "" && c.Key != "":
+		creds, err = pdf.LoadPEM(c.Certificate, c.Key)
+	default:
+		err = errors.New("either pkcs12 or certificate and key must be configured")
+	}
+	if err != nil {
+		return fmt.Errorf("load credentials: %w", err)
+	}
+
+	opts := pdf.SignOpts{
+		Name:        c.Name,
+		Reason:      c.Reason,
+		Location:    c.Location,
+		ContactInfo: c.ContactInfo,
+	}
+	if a := c.Appearance; a != nil {
+		opts.Appearance = &pdf.SignatureAppearance{
+			Page:   a.Page,
+			X:      a.X,
+			Y:      a.Y,
+			Width:  a.Width,
+			Height: a.Height,
+		}
+	}
+
+	return pdf.Sign(document, w, creds, opts)
+}
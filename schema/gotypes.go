@@ -0,0 +1,346 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// initialisms are written in upper case in Go names
+var initialisms = map[string]bool{
+	"api": true, "css": true, "html": true, "http": true, "id": true, "ip": true,
+	"json": true, "pdf": true, "qr": true, "uri": true, "url": true, "uuid": true,
+}
+
+// GenerateGo generates Go types for the schemas of templates, keyed by the
+// template name. Each template gets a struct type named after it, e.g. Invoice
+// for the invoice template, which can be passed to Client.Render. Nested
+// objects and $defs become struct types prefixed with that name. Optional
+// properties are pointers, unless they are slices or maps, and tagged with
+// omitempty.
+func GenerateGo(pkg string, schemas map[string][]byte) ([]byte, error) {
+	g := &generator{names: make(map[string]bool)}
+	for _, name := range slices.Sorted(maps.Keys(schemas)) {
+		var root map[string]any
+		if err := json.Unmarshal(schemas[name], &root); err != nil {
+			return nil, fmt.Errorf("decode schema of %s: %w", name, err)
+		}
+		typeName := g.unique(goName(name))
+		t := &typeGenerator{
+			generator: g,
+			template:  name,
+			root:      root,
+			prefix:    typeName,
+			refs:      map[string]string{"#": typeName},
+		}
+		comment := fmt.Sprintf("%s are the values of the %s template", typeName, name)
+		if _, err := t.declare(typeName, comment, t.merge(root)); err != nil {
+			return nil, fmt.Errorf("generate types of %s: %w", name, err)
+		}
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by httpdf types. DO NOT EDIT.\n\npackage %s\n", pkg)
+	for _, decl := range g.decls {
+		src.WriteString("\n")
+		src.WriteString(decl)
+	}
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format source: %w", err)
+	}
+	return formatted, nil
+}
+
+// generator collects the type declarations of all templates
+type generator struct {
+	decls []string
+	names map[string]bool
+}
+
+// unique returns name, or name with a number appended if it's already taken
+func (g *generator) unique(name string) string {
+	unique := name
+	for i := 2; g.names[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	g.names[unique] = true
+	return unique
+}
+
+// typeGenerator generates the types of a single schema
+type typeGenerator struct {
+	*generator
+	template string
+	root     map[string]any
+	prefix   string
+	// refs maps references to the types generated for them
+	refs map[string]string
+}
+
+// goType returns the Go type of the schema. name is used for the type
+// declaration of objects.
+func (t *typeGenerator) goType(s map[string]any, name string) (string, error) {
+	if ref, ok := s["$ref"].(string); ok {
+		return t.ref(ref)
+	}
+	if _, ok := s["allOf"]; ok {
+		s = t.merge(s)
+	}
+
+	types := schemaTypes(s)
+	if len(types) != 1 {
+		return "any", nil
+	}
+	switch types[0] {
+	case "string":
+		return "string", nil
+	case "integer":
+		return "int", nil
+	case "number":
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		items, ok := s["items"].(map[string]any)
+		if !ok {
+			return "[]any", nil
+		}
+		item, err := t.goType(items, name+"Item")
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	case "object":
+		if _, ok := s["properties"].(map[string]any); ok {
+			return t.declare(t.unique(name), description(s), s)
+		}
+		values, ok := s["additionalProperties"].(map[string]any)
+		if !ok {
+			return "map[string]any", nil
+		}
+		value, err := t.goType(values, name+"Value")
+		if err != nil {
+			return "", err
+		}
+		return "map[string]" + value, nil
+	default:
+		return "any", nil
+	}
+}
+
+// ref returns the Go type of the referenced definition
+func (t *typeGenerator) ref(ref string) (string, error) {
+	if name, ok := t.refs[ref]; ok {
+		return name, nil
+	}
+	def := t.resolve(ref)
+	if def == nil {
+		return "", fmt.Errorf("unsupported reference %q", ref)
+	}
+	name := t.prefix + goName(ref[strings.LastIndex(ref, "/")+1:])
+
+	// Objects are named before their fields are generated, as they may
+	// refer to themselves
+	if _, ok := t.merge(def)["properties"]; ok {
+		name = t.unique(name)
+		t.refs[ref] = name
+		return t.declare(name, description(def), t.merge(def))
+	}
+	goType, err := t.goType(def, name)
+	if err != nil {
+		return "", err
+	}
+	t.refs[ref] = goType
+	return goType, nil
+}
+
+// declare adds the declaration of a struct type for the object schema
+func (t *typeGenerator) declare(name, comment string, s map[string]any) (string, error) {
+	properties, _ := s["properties"].(map[string]any)
+	required := make(map[string]bool)
+	if list, ok := s["required"].([]any); ok {
+		for _, r := range list {
+			if key, ok := r.(string); ok {
+				required[key] = true
+			}
+		}
+	}
+
+	// The declaration is added before the fields are generated, so that
+	// it precedes the types of its fields
+	i := len(t.decls)
+	t.decls = append(t.decls, "")
+
+	if comment == "" {
+		comment = fmt.Sprintf("%s is part of the values of the %s template", name, t.template)
+	}
+	var decl strings.Builder
+	writeComment(&decl, "", comment)
+	fmt.Fprintf(&decl, "type %s struct {\n", name)
+	fields := make(map[string]bool)
+	for _, key := range slices.Sorted(maps.Keys(properties)) {
+		property, _ := properties[key].(map[string]any)
+		fieldName := goName(key)
+		for n := 2; fields[fieldName]; n++ {
+			fieldName = goName(key) + strconv.Itoa(n)
+		}
+		fields[fieldName] = true
+
+		fieldType, err := t.goType(property, name+goName(key))
+		if err != nil {
+			return "", fmt.Errorf("property %s: %w", key, err)
+		}
+		tag := key
+		if !required[key] {
+			tag += ",omitempty"
+			if nullable(fieldType) {
+				fieldType = "*" + fieldType
+			}
+		}
+		writeComment(&decl, "\t", description(property))
+		fmt.Fprintf(&decl, "\t%s %s `json:%q`\n", fieldName, fieldType, tag)
+	}
+	decl.WriteString("}\n")
+
+	t.decls[i] = decl.String()
+	return name, nil
+}
+
+// merge combines the object schemas of allOf into a single one
+func (t *typeGenerator) merge(s map[string]any) map[string]any {
+	all, ok := s["allOf"].([]any)
+	if !ok {
+		return s
+	}
+
+	merged := maps.Clone(s)
+	delete(merged, "allOf")
+	properties := make(map[string]any)
+	var required []any
+	add := func(part map[string]any) {
+		if p, ok := part["properties"].(map[string]any); ok {
+			maps.Copy(properties, p)
+		}
+		if r, ok := part["required"].([]any); ok {
+			required = append(required, r...)
+		}
+	}
+	for _, entry := range all {
+		part, _ := entry.(map[string]any)
+		if ref, ok := part["$ref"].(string); ok {
+			part = t.resolve(ref)
+		}
+		add(t.merge(part))
+	}
+	add(s)
+
+	merged["type"] = "object"
+	merged["properties"] = properties
+	merged["required"] = required
+	return merged
+}
+
+// resolve returns the definition the local reference points to, e.g.
+// #/$defs/address
+func (t *typeGenerator) resolve(ref string) map[string]any {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil
+	}
+	var v any = t.root
+	if pointer != "" {
+		for segment := range strings.SplitSeq(strings.TrimPrefix(pointer, "/"), "/") {
+			segment = strings.NewReplacer("~1", "/", "~0", "~").Replace(segment)
+			switch node := v.(type) {
+			case map[string]any:
+				v = node[segment]
+			case []any:
+				i, err := strconv.Atoi(segment)
+				if err != nil || i < 0 || i >= len(node) {
+					return nil
+				}
+				v = node[i]
+			default:
+				return nil
+			}
+		}
+	}
+	def, _ := v.(map[string]any)
+	return def
+}
+
+// schemaTypes returns the types of the schema except null, inferring them
+// from the keywords if the type is missing
+func schemaTypes(s map[string]any) []string {
+	var types []string
+	switch v := s["type"].(type) {
+	case string:
+		types = []string{v}
+	case []any:
+		for _, t := range v {
+			if t, ok := t.(string); ok && t != "null" {
+				types = append(types, t)
+			}
+		}
+	default:
+		if _, ok := s["properties"]; ok {
+			types = []string{"object"}
+		} else if _, ok := s["items"]; ok {
+			types = []string{"array"}
+		}
+	}
+	return types
+}
+
+// nullable reports whether the Go type needs a pointer to represent a missing
+// value
+func nullable(goType string) bool {
+	return goType != "any" && !strings.HasPrefix(goType, "[]") && !strings.HasPrefix(goType, "map[")
+}
+
+func description(s map[string]any) string {
+	d, _ := s["description"].(string)
+	return d
+}
+
+func writeComment(b *strings.Builder, indent, comment string) {
+	if comment == "" {
+		return
+	}
+	for line := range strings.SplitSeq(strings.TrimSpace(comment), "\n") {
+		fmt.Fprintf(b, "%s// %s\n", indent, strings.TrimSpace(line))
+	}
+}
+
+// goName converts a property or template name into an exported Go name, e.g.
+// unit_price to UnitPrice
+func goName(s string) string {
+	var b strings.Builder
+	for part := range strings.FieldsFuncSeq(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if initialisms[strings.ToLower(part)] {
+			b.WriteString(strings.ToUpper(part))
+			continue
+		}
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+
+	name := b.String()
+	if name == "" {
+		return "Field"
+	}
+	if unicode.IsDigit([]rune(name)[0]) {
+		return "N" + name
+	}
+	return name
+}
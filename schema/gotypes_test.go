@@ -0,0 +1,150 @@
+package schema_test
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/sehrgutesoftware/httpdf/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generate(t *testing.T, schemas map[string]string) string {
+	t.Helper()
+	raw := make(map[string][]byte, len(schemas))
+	for name, s := range schemas {
+		raw[name] = []byte(s)
+	}
+
+	src, err := schema.GenerateGo("templates", raw)
+
+	require.NoError(t, err)
+	_, err = parser.ParseFile(token.NewFileSet(), "types.go", src, parser.ParseComments)
+	require.NoError(t, err, string(src))
+	return string(src)
+}
+
+func TestGenerateGo(t *testing.T) {
+	t.Run("it_generates_a_struct_per_template", func(t *testing.T) {
+		src := generate(t, map[string]string{
+			"invoice":        `{"type": "object", "properties": {"number": {"type": "string"}}, "required": ["number"]}`,
+			"shipping-label": `{"type": "object", "properties": {"tracking_id": {"type": "string", "description": "Tracking number"}}}`,
+		})
+
+		assert.Contains(t, src, "// Code generated by httpdf types. DO NOT EDIT.\n\npackage templates\n")
+		assert.Contains(t, src, "// Invoice are the values of the invoice template\ntype Invoice struct {\n\tNumber string `json:\"number\"`\n}")
+		assert.Contains(t, src, "type ShippingLabel struct {\n\t// Tracking number\n\tTrackingID *string `json:\"tracking_id,omitempty\"`\n}")
+	})
+
+	t.Run("it_maps_the_json_types", func(t *testing.T) {
+		src := generate(t, map[string]string{"t": `{
+			"type": "object",
+			"properties": {
+				"count": {"type": "integer"},
+				"amount": {"type": "number"},
+				"paid": {"type": "boolean"},
+				"tags": {"type": "array", "items": {"type": "string"}},
+				"extra": {"type": "object", "additionalProperties": {"type": "number"}},
+				"anything": {},
+				"note": {"type": ["string", "null"]}
+			},
+			"required": ["count", "amount", "paid", "tags", "extra", "anything", "note"]
+		}`})
+
+		assert.Contains(t, src, "Count    int                `json:\"count\"`")
+		assert.Contains(t, src, "Amount   float64            `json:\"amount\"`")
+		assert.Contains(t, src, "Paid     bool               `json:\"paid\"`")
+		assert.Contains(t, src, "Tags     []string           `json:\"tags\"`")
+		assert.Contains(t, src, "Extra    map[string]float64 `json:\"extra\"`")
+		assert.Contains(t, src, "Anything any                `json:\"anything\"`")
+		assert.Contains(t, src, "Note     string             `json:\"note\"`")
+	})
+
+	t.Run("it_generates_nested_structs", func(t *testing.T) {
+		src := generate(t, map[string]string{"invoice": `{
+			"type": "object",
+			"properties": {
+				"items": {"type": "array", "items": {"type": "object", "properties": {"name": {"type": "string"}}}},
+				"customer": {"type": "object", "properties": {"name": {"type": "string"}}}
+			}
+		}`})
+
+		assert.Contains(t, src, "Customer *InvoiceCustomer   `json:\"customer,omitempty\"`")
+		assert.Contains(t, src, "Items    []InvoiceItemsItem `json:\"items,omitempty\"`")
+		assert.Contains(t, src, "type InvoiceCustomer struct {")
+		assert.Contains(t, src, "type InvoiceItemsItem struct {")
+	})
+
+	t.Run("it_generates_a_single_type_per_definition", func(t *testing.T) {
+		src := generate(t, map[string]string{"letter": `{
+			"type": "object",
+			"$defs": {
+				"party": {"type": "object", "properties": {"name": {"type": "string"}, "parent": {"$ref": "#/$defs/party"}}},
+				"date": {"type": "string"}
+			},
+			"properties": {
+				"sender": {"$ref": "#/$defs/party"},
+				"recipient": {"$ref": "#/$defs/party"},
+				"date": {"$ref": "#/$defs/date"}
+			},
+			"required": ["sender", "recipient", "date"]
+		}`})
+
+		assert.Contains(t, src, "Recipient LetterParty `json:\"recipient\"`")
+		assert.Contains(t, src, "Sender    LetterParty `json:\"sender\"`")
+		assert.Contains(t, src, "Date      string      `json:\"date\"`")
+		assert.Contains(t, src, "Parent *LetterParty `json:\"parent,omitempty\"`")
+		assert.Equal(t, 1, strings.Count(src, "type LetterParty struct"))
+	})
+
+	t.Run("it_merges_all_of", func(t *testing.T) {
+		src := generate(t, map[string]string{"t": `{
+			"allOf": [
+				{"type": "object", "properties": {"base": {"type": "string"}}, "required": ["base"]},
+				{"type": "object", "properties": {"extra": {"type": "integer"}}}
+			]
+		}`})
+
+		assert.Contains(t, src, "Base  string `json:\"base\"`")
+		assert.Contains(t, src, "Extra *int   `json:\"extra,omitempty\"`")
+	})
+
+	t.Run("it_resolves_references_into_nested_definitions", func(t *testing.T) {
+		src := generate(t, map[string]string{"invoice": `{
+			"$defs": {
+				"base": {
+					"type": "object",
+					"$defs": {"party": {"type": "object", "properties": {"name": {"type": "string"}}}},
+					"properties": {"sender": {"$ref": "#/$defs/base/$defs/party"}},
+					"required": ["sender"]
+				},
+				"invoice": {"type": "object", "properties": {"number": {"type": "string"}}, "required": ["number"]}
+			},
+			"allOf": [{"$ref": "#/$defs/base"}, {"$ref": "#/$defs/invoice"}]
+		}`})
+
+		assert.Contains(t, src, "Number string       `json:\"number\"`")
+		assert.Contains(t, src, "Sender InvoiceParty `json:\"sender\"`")
+		assert.Contains(t, src, "type InvoiceParty struct")
+	})
+
+	t.Run("it_returns_an_error_for_unsupported_references", func(t *testing.T) {
+		_, err := schema.GenerateGo("templates", map[string][]byte{
+			"t": []byte(`{"type": "object", "properties": {"a": {"$ref": "other.json"}}}`),
+		})
+
+		assert.ErrorContains(t, err, "unsupported reference")
+	})
+
+	t.Run("it_generates_types_for_schemas_derived_from_structs", func(t *testing.T) {
+		derived, err := schema.FromStruct(invoice{})
+		require.NoError(t, err)
+
+		src := generate(t, map[string]string{"invoice": string(derived)})
+
+		assert.Contains(t, src, "Items    []InvoiceItem     `json:\"items\"`")
+		assert.Contains(t, src, "Seller   *InvoiceParty     `json:\"seller,omitempty\"`")
+	})
+}
@@ -0,0 +1,315 @@
+// Package schema keeps the values passed to templates and the templates'
+// schema.json in sync. FromStruct derives a JSON Schema from an annotated Go
+// struct, Example encodes a value of that struct as example data, and
+// GenerateGo generates Go types from the schemas of templates.
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaptinlin/jsonschema"
+)
+
+var (
+	// ErrNotStruct is returned if the value passed to FromStruct isn't a
+	// struct or a pointer to one
+	ErrNotStruct = errors.New("not a struct")
+	// ErrInvalidTag is returned for malformed jsonschema struct tags
+	ErrInvalidTag = errors.New("invalid jsonschema tag")
+	// ErrInvalidExample is returned if the example doesn't match the schema
+	// derived from its type
+	ErrInvalidExample = errors.New("example doesn't match the schema")
+)
+
+// draft is the JSON Schema dialect of the generated schemas
+const draft = "https://json-schema.org/draft/2020-12/schema"
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+// FromStruct derives a JSON Schema from the type of v, which must be a struct
+// or a pointer to one. Fields are named as encoding/json would name them and
+// are required unless tagged with omitempty or omitzero. The jsonschema tag
+// adds keywords to a field as comma-separated list, e.g.
+//
+//	Amount float64 `json:"amount" jsonschema:"minimum=0,description=Net amount"`
+//
+// Supported are title, description, format, pattern, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, multipleOf, minLength, maxLength,
+// minItems, maxItems, enum (values separated by |), default, and the flags
+// required and optional, which override the json tag. Named struct types
+// other than v's are placed in $defs.
+func FromStruct(v any) ([]byte, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %T", ErrNotStruct, v)
+	}
+
+	r := &reflector{
+		defs: make(map[string]any),
+		refs: map[reflect.Type]string{t: "#"},
+	}
+	s, err := r.object(t)
+	if err != nil {
+		return nil, err
+	}
+	s["$schema"] = draft
+	if len(r.defs) > 0 {
+		s["$defs"] = r.defs
+	}
+
+	return json.MarshalIndent(s, "", "    ")
+}
+
+// Example encodes v as example data for a template, after checking that it
+// matches the schema derived from its type
+func Example(v any) ([]byte, error) {
+	raw, err := FromStruct(v)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := jsonschema.NewCompiler().Compile(raw)
+	if err != nil {
+		return nil, fmt.Errorf("compile schema: %w", err)
+	}
+
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("encode example: %w", err)
+	}
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("decode example: %w", err)
+	}
+	if valid := compiled.Validate(values); !valid.Valid {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExample, valid.Errors)
+	}
+
+	return data, nil
+}
+
+// reflector derives the schemas of Go types
+type reflector struct {
+	defs map[string]any
+	// refs maps the named struct types to their reference
+	refs map[reflect.Type]string
+}
+
+// schema returns the schema of t
+func (r *reflector) schema(t reflect.Type) (map[string]any, error) {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}, nil
+	case rawMessageType:
+		return map[string]any{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return r.schema(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Slice, reflect.Array:
+		// encoding/json encodes byte slices as base64 strings
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}, nil
+		}
+		items, err := r.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Map:
+		values, err := r.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		return r.ref(t)
+	case reflect.Interface:
+		return map[string]any{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+// ref returns a reference to the named struct type, adding it to $defs. Anonymous
+// structs are inlined.
+func (r *reflector) ref(t reflect.Type) (map[string]any, error) {
+	if t.Name() == "" {
+		return r.object(t)
+	}
+	if ref, ok := r.refs[t]; ok {
+		return map[string]any{"$ref": ref}, nil
+	}
+
+	name := t.Name()
+	for i := 2; r.defs[name] != nil; i++ {
+		name = t.Name() + strconv.Itoa(i)
+	}
+	ref := "#/$defs/" + name
+	r.refs[t] = ref
+	// Reserve the name, as the type may refer to itself
+	r.defs[name] = map[string]any{}
+	def, err := r.object(t)
+	if err != nil {
+		return nil, err
+	}
+	r.defs[name] = def
+	return map[string]any{"$ref": ref}, nil
+}
+
+// object returns the schema of the fields of the struct type
+func (r *reflector) object(t reflect.Type) (map[string]any, error) {
+	properties := make(map[string]any)
+	required := []string{}
+	if err := r.fields(t, properties, &required); err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}, nil
+}
+
+// fields adds the fields of the struct type to properties, including those
+// of embedded structs
+func (r *reflector) fields(t reflect.Type, properties map[string]any, required *[]string) error {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := r.fields(embedded, properties, required); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s, err := r.schema(f.Type)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		isRequired := !hasOption(opts, "omitempty") && !hasOption(opts, "omitzero")
+		if isRequired, err = annotate(s, f, isRequired); err != nil {
+			return err
+		}
+
+		properties[name] = s
+		if isRequired {
+			*required = append(*required, name)
+		}
+	}
+	return nil
+}
+
+// annotate adds the keywords of the field's jsonschema tag to the schema and
+// returns whether the field is required
+func annotate(s map[string]any, f reflect.StructField, required bool) (bool, error) {
+	tag := f.Tag.Get("jsonschema")
+	if tag == "" {
+		return required, nil
+	}
+	t := f.Type
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	kind := t.Kind()
+
+	for entry := range strings.SplitSeq(tag, ",") {
+		key, value, _ := strings.Cut(entry, "=")
+		var err error
+		switch key {
+		case "required":
+			required = true
+		case "optional":
+			required = false
+		case "title", "description", "format", "pattern":
+			s[key] = value
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf":
+			s[key], err = strconv.ParseFloat(value, 64)
+		case "minLength", "maxLength", "minItems", "maxItems":
+			s[key], err = strconv.Atoi(value)
+		case "default":
+			s[key], err = parseValue(kind, value)
+		case "enum":
+			var values []any
+			for v := range strings.SplitSeq(value, "|") {
+				parsed, err := parseValue(kind, v)
+				if err != nil {
+					return false, fmt.Errorf("%w: field %s: %s: %w", ErrInvalidTag, f.Name, key, err)
+				}
+				values = append(values, parsed)
+			}
+			s[key] = values
+		default:
+			return false, fmt.Errorf("%w: field %s: unknown keyword %q", ErrInvalidTag, f.Name, key)
+		}
+		if err != nil {
+			return false, fmt.Errorf("%w: field %s: %s: %w", ErrInvalidTag, f.Name, key, err)
+		}
+	}
+	return required, nil
+}
+
+// parseValue parses a value of a tag according to the kind of the field
+func parseValue(kind reflect.Kind, value string) (any, error) {
+	switch kind {
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseInt(value, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(value, 64)
+	default:
+		return value, nil
+	}
+}
+
+func hasOption(opts, option string) bool {
+	for opt := range strings.SplitSeq(opts, ",") {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
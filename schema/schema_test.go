@@ -0,0 +1,159 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kaptinlin/jsonschema"
+	"github.com/sehrgutesoftware/httpdf/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type party struct {
+	Name    string   `json:"name" jsonschema:"minLength=1"`
+	Address []string `json:"address,omitempty"`
+}
+
+type item struct {
+	Description string  `json:"description"`
+	Quantity    int     `json:"quantity" jsonschema:"minimum=1,default=1"`
+	UnitPrice   float64 `json:"unitPrice"`
+}
+
+type meta struct {
+	Number string `json:"number" jsonschema:"pattern=^[0-9]+$"`
+}
+
+type invoice struct {
+	meta
+	Customer party     `json:"customer"`
+	Seller   *party    `json:"seller,omitempty"`
+	Date     time.Time `json:"date"`
+	Status   string    `json:"status" jsonschema:"enum=draft|final,description=Status of the invoice"`
+	Items    []item    `json:"items"`
+	Notes    map[string]string
+	Paid     bool `json:"paid" jsonschema:"optional"`
+	internal string
+	Ignored  string `json:"-"`
+}
+
+type node struct {
+	Name     string `json:"name"`
+	Children []node `json:"children,omitempty"`
+}
+
+func decode(t *testing.T, data []byte) map[string]any {
+	t.Helper()
+	var v map[string]any
+	require.NoError(t, json.Unmarshal(data, &v))
+	return v
+}
+
+func TestFromStruct(t *testing.T) {
+	t.Run("it_derives_the_properties_from_the_json_names", func(t *testing.T) {
+		data, err := schema.FromStruct(invoice{})
+
+		require.NoError(t, err)
+		s := decode(t, data)
+		assert.Equal(t, "https://json-schema.org/draft/2020-12/schema", s["$schema"])
+		assert.ElementsMatch(t,
+			[]string{"number", "customer", "seller", "date", "status", "items", "Notes", "paid"},
+			keys(s["properties"].(map[string]any)),
+		)
+		assert.ElementsMatch(t, []any{"number", "customer", "date", "status", "items", "Notes"}, s["required"])
+	})
+
+	t.Run("it_maps_the_go_types", func(t *testing.T) {
+		data, err := schema.FromStruct(&invoice{})
+
+		require.NoError(t, err)
+		properties := decode(t, data)["properties"].(map[string]any)
+		assert.Equal(t, map[string]any{"type": "string", "format": "date-time"}, properties["date"])
+		assert.Equal(t, map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}}, properties["Notes"])
+		assert.Equal(t, map[string]any{"type": "array", "items": map[string]any{"$ref": "#/$defs/item"}}, properties["items"])
+		assert.Equal(t, map[string]any{"$ref": "#/$defs/party"}, properties["seller"])
+	})
+
+	t.Run("it_applies_the_jsonschema_tags", func(t *testing.T) {
+		data, err := schema.FromStruct(invoice{})
+
+		require.NoError(t, err)
+		s := decode(t, data)
+		properties := s["properties"].(map[string]any)
+		assert.Equal(t, map[string]any{
+			"type":        "string",
+			"enum":        []any{"draft", "final"},
+			"description": "Status of the invoice",
+		}, properties["status"])
+		assert.Equal(t, "^[0-9]+$", properties["number"].(map[string]any)["pattern"])
+		quantity := s["$defs"].(map[string]any)["item"].(map[string]any)["properties"].(map[string]any)["quantity"]
+		assert.Equal(t, map[string]any{"type": "integer", "minimum": 1.0, "default": 1.0}, quantity)
+	})
+
+	t.Run("it_references_recursive_types", func(t *testing.T) {
+		data, err := schema.FromStruct(node{})
+
+		require.NoError(t, err)
+		children := decode(t, data)["properties"].(map[string]any)["children"]
+		assert.Equal(t, map[string]any{"type": "array", "items": map[string]any{"$ref": "#"}}, children)
+	})
+
+	t.Run("it_generates_a_valid_schema", func(t *testing.T) {
+		data, err := schema.FromStruct(invoice{})
+		require.NoError(t, err)
+
+		compiled, err := jsonschema.NewCompiler().Compile(data)
+
+		require.NoError(t, err)
+		valid := map[string]any{
+			"number":   "1",
+			"customer": map[string]any{"name": "Jane Doe"},
+			"date":     "2025-01-31T00:00:00Z",
+			"status":   "draft",
+			"items":    []any{map[string]any{"description": "Consulting", "quantity": 2, "unitPrice": 120}},
+			"Notes":    map[string]any{},
+		}
+		assert.True(t, compiled.Validate(valid).Valid)
+		valid["status"] = "sent"
+		assert.False(t, compiled.Validate(valid).Valid)
+	})
+
+	t.Run("it_returns_an_error_for_other_types_than_structs", func(t *testing.T) {
+		_, err := schema.FromStruct(map[string]any{})
+
+		assert.ErrorIs(t, err, schema.ErrNotStruct)
+	})
+
+	t.Run("it_returns_an_error_for_unknown_tag_keywords", func(t *testing.T) {
+		_, err := schema.FromStruct(struct {
+			Name string `json:"name" jsonschema:"minimun=1"`
+		}{})
+
+		assert.ErrorIs(t, err, schema.ErrInvalidTag)
+	})
+}
+
+func TestExample(t *testing.T) {
+	t.Run("it_encodes_the_value", func(t *testing.T) {
+		data, err := schema.Example(party{Name: "Jane Doe"})
+
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"name": "Jane Doe"}`, string(data))
+	})
+
+	t.Run("it_rejects_values_not_matching_the_schema", func(t *testing.T) {
+		_, err := schema.Example(party{})
+
+		assert.ErrorIs(t, err, schema.ErrInvalidExample)
+	})
+}
+
+func keys(m map[string]any) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -1,38 +1,120 @@
 package httpdf
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/fs"
+	"maps"
 	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/handlers"
+	"github.com/kaptinlin/jsonschema"
+	"github.com/sehrgutesoftware/httpdf/internal/pdf"
 	"github.com/sehrgutesoftware/httpdf/internal/template"
 )
 
+const (
+	headerUserPassword  = "X-PDF-User-Password"
+	headerOwnerPassword = "X-PDF-Owner-Password"
+	headerPermissions   = "X-PDF-Permissions"
+	headerDiagnostics   = "X-PDF-Diagnostics"
+
+	// maxUploadSize limits the size of ad-hoc templates, including assets
+	maxUploadSize = 32 << 20
+	// uploadName is the name of an ad-hoc template in its in-memory filesystem
+	uploadName = "upload"
+
+	// The page size of POST /html and POST /url defaults to A4
+	defaultPageWidth  = 210
+	defaultPageHeight = 297
+)
+
 type server struct {
 	*http.ServeMux
 	httpdf HTTPDF
 	loader template.Loader
 	cache  map[string]*template.Template
+
+	diagnosticsHeader bool
+	adHocTemplates    bool
+	urlAllowlist      []string
+	devTemplates      fs.FS
+}
+
+// ServerOption configures the server
+type ServerOption func(*server)
+
+// WithDiagnosticsHeader adds the diagnostics reported by the page while
+// rendering to the X-PDF-Diagnostics response header, as JSON array. This may
+// expose details of the templates, so it's meant for development.
+func WithDiagnosticsHeader() ServerOption {
+	return func(s *server) {
+		s.diagnosticsHeader = true
+	}
 }
 
-func NewServer(httpdf HTTPDF, loader template.Loader) http.Handler {
+// WithAdHocTemplates enables POST /render, which renders a template uploaded
+// in the request instead of one from the templates directory. Uploaded
+// templates are rendered with the server's privileges (e.g. the env function),
+// so this should be disabled in production.
+func WithAdHocTemplates() ServerOption {
+	return func(s *server) {
+		s.adHocTemplates = true
+	}
+}
+
+// WithURLAllowlist allows POST /url to render pages on the given hosts. The
+// entries have the same format as the network.allow template config, e.g.
+// "intranet.example.com", "*.example.com" or "https://example.com/reports/*".
+// Pages can request resources from the same hosts. Without allowlist, all URLs
+// are rejected.
+func WithURLAllowlist(allow ...string) ServerOption {
+	return func(s *server) {
+		s.urlAllowlist = append(s.urlAllowlist, allow...)
+	}
+}
+
+func NewServer(httpdf HTTPDF, loader template.Loader, opts ...ServerOption) http.Handler {
 	server := &server{
 		ServeMux: http.NewServeMux(),
 		httpdf:   httpdf,
 		loader:   loader,
 		cache:    make(map[string]*template.Template),
 	}
+	for _, opt := range opts {
+		opt(server)
+	}
 
 	server.Handle("POST /templates/{template}/render", http.HandlerFunc(server.render))
 	server.Handle("GET /templates/{template}/preview", http.HandlerFunc(server.preview))
+	server.Handle("POST /templates/{template}/preview", http.HandlerFunc(server.preview))
 	server.Handle("GET /templates/{template}/assets/", http.HandlerFunc(server.assets))
+	server.Handle("GET /templates/{template}/examples", http.HandlerFunc(server.examples))
+	server.Handle("POST /html", http.HandlerFunc(server.renderHTML))
+	server.Handle("POST /url", http.HandlerFunc(server.renderURL))
+	if server.devTemplates != nil {
+		server.Handle("GET /templates/{template}/events", http.HandlerFunc(server.events))
+	}
+	if server.adHocTemplates {
+		server.Handle("POST /render", http.HandlerFunc(server.renderUpload))
+	}
 
 	cors := handlers.CORS(
 		handlers.AllowedOrigins([]string{"*"}),
 		handlers.AllowedMethods([]string{http.MethodOptions, http.MethodGet, http.MethodPost}),
-		handlers.AllowedHeaders([]string{"Content-Type"}),
+		handlers.AllowedHeaders([]string{"Content-Type", headerUserPassword, headerOwnerPassword, headerPermissions}),
+		handlers.ExposedHeaders([]string{headerDiagnostics}),
 	)
 
 	return cors(server)
@@ -55,12 +137,227 @@ func (s *server) render(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.generate(w, r, t, values)
+}
+
+// renderUpload renders an ad-hoc template from a multipart form. Each file is
+// stored under the path given by its field name, e.g. template.html or
+// assets/logo.png; files in the assets and locales fields are stored in the
+// respective directory under their file name. The values are read from the
+// values field.
+func (s *server) renderUpload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	values := map[string]any{}
+	if v := r.MultipartForm.Value["values"]; len(v) > 0 {
+		if err := json.Unmarshal([]byte(v[0]), &values); err != nil {
+			http.Error(w, "invalid values: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	files := uploadFS{}
+	for field, headers := range r.MultipartForm.File {
+		for _, header := range headers {
+			name := field
+			if field == "assets" || field == "locales" {
+				name = path.Join(field, path.Base(header.Filename))
+			}
+			if !uploadAllowed(name) {
+				http.Error(w, fmt.Sprintf("unexpected file %q", name), http.StatusBadRequest)
+				return
+			}
+
+			f, err := header.Open()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data, err := io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			files[path.Join(uploadName, name)] = data
+		}
+	}
+
+	// The template is loaded using the same loader as the templates directory
+	t, err := template.NewFSLoader(files).Load(uploadName)
+	if err != nil {
+		http.Error(w, strings.ReplaceAll(err.Error(), uploadName+"/", ""), http.StatusBadRequest)
+		return
+	}
+	if err := checkUploadConfig(t.Config); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.generate(w, r, t, values)
+}
+
+// checkUploadConfig rejects config of ad-hoc templates that would give them
+// access to the server: its signing credentials, its environment and the
+// network beyond the request allowlist
+func checkUploadConfig(c template.Config) error {
+	switch {
+	case c.Signature != nil:
+		return errors.New("config.yaml: signature isn't allowed for ad-hoc templates")
+	case len(c.ExposedEnvVars) > 0:
+		return errors.New("config.yaml: exposedEnvVars isn't allowed for ad-hoc templates")
+	case len(c.Network.Allow) > 0:
+		return errors.New("config.yaml: network.allow isn't allowed for ad-hoc templates")
+	}
+	return nil
+}
+
+// uploadAllowed reports whether an ad-hoc template may contain the file
+func uploadAllowed(name string) bool {
+	if !fs.ValidPath(name) {
+		return false
+	}
+	switch name {
+	case "template.html", "config.yaml", "schema.json", "example.json":
+		return true
+	}
+	if dir, file, ok := strings.Cut(name, "/"); ok {
+		switch dir {
+		case "assets":
+			return true
+		case "locales":
+			return !strings.Contains(file, "/") && path.Ext(file) == ".yaml"
+		}
+	}
+	return false
+}
+
+// htmlRequest is the JSON request body of POST /html
+type htmlRequest struct {
+	HTML string `json:"html"`
+	// Assets maps paths relative to the page to base64-encoded content
+	Assets map[string]string `json:"assets"`
+}
+
+// renderHTML renders raw HTML, either sent as text/html body or as JSON
+// together with assets
+func (s *server) renderHTML(w http.ResponseWriter, r *http.Request) {
+	opts, err := extractPage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req htmlRequest
+	if mediaType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";"); strings.TrimSpace(mediaType) == "text/html" {
+		html, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.HTML = string(html)
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var assets fs.FS
+	if len(req.Assets) > 0 {
+		files := uploadFS{}
+		for name, content := range req.Assets {
+			name = strings.TrimPrefix(name, "/")
+			if !fs.ValidPath(name) || name == "." {
+				http.Error(w, fmt.Sprintf("invalid asset path %q", name), http.StatusBadRequest)
+				return
+			}
+			data, err := base64.StdEncoding.DecodeString(content)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid asset %s: %v", name, err), http.StatusBadRequest)
+				return
+			}
+			files[name] = data
+		}
+		assets = files
+	}
+
 	w.Header().Set("Content-Type", "application/pdf")
-	if err := s.httpdf.Generate(r.Context(), t, extractLocale(r), values, w); err != nil {
+	if err := s.httpdf.RenderHTML(r.Context(), []byte(req.HTML), assets, opts, w); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
+// urlRequest is the JSON request body of POST /url
+type urlRequest struct {
+	URL string `json:"url"`
+}
+
+// renderURL renders the page at a URL from the allowlist
+func (s *server) renderURL(w http.ResponseWriter, r *http.Request) {
+	opts, err := extractPage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req urlRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(w, "invalid URL", http.StatusBadRequest)
+		return
+	}
+	policy := pdf.NetworkPolicy{Allow: s.urlAllowlist}
+	if !policy.Allows("", u.String()) {
+		http.Error(w, "URL not allowed", http.StatusForbidden)
+		return
+	}
+
+	opts.Network = policy
+	w.Header().Set("Content-Type", "application/pdf")
+	if err := s.httpdf.RenderURL(r.Context(), u.String(), opts, w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// generate renders the template as PDF to the response
+func (s *server) generate(w http.ResponseWriter, r *http.Request, t *template.Template, values map[string]any) {
+	var opts []GenerateOption
+	if encryption := extractEncryption(r); encryption != nil {
+		opts = append(opts, WithEncryption(*encryption))
+	}
+	if watermarks := r.URL.Query()["watermark"]; len(watermarks) > 0 {
+		opts = append(opts, WithWatermarks(watermarks...))
+	}
+	if s.diagnosticsHeader {
+		opts = append(opts, WithDiagnostics(func(d pdf.Diagnostics) {
+			if len(d) == 0 {
+				return
+			}
+			if encoded, err := json.Marshal(d); err == nil {
+				w.Header().Set(headerDiagnostics, string(encoded))
+			}
+		}))
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	err := s.httpdf.Generate(r.Context(), t, extractLocale(r), values, w, opts...)
+	if errors.Is(err, template.ErrMissingKey) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// preview renders the template as HTML, with the values from example.json or,
+// for POST requests, from the request body. Validation errors are shown at the
+// top of the page. With ?format=pdf, the PDF is rendered instead.
 func (s *server) preview(w http.ResponseWriter, r *http.Request) {
 	assets := fmt.Sprintf("/templates/%s/assets", r.PathValue("template"))
 
@@ -71,16 +368,132 @@ func (s *server) preview(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "template not found", http.StatusNotFound)
 		return
 	} else if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.previewError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	values := t.Example
+	if name := r.URL.Query().Get("example"); name != "" {
+		example, ok := t.Examples[name]
+		if !ok {
+			http.Error(w, "example not found", http.StatusNotFound)
+			return
+		}
+		values = example
+	}
+	if r.Method == http.MethodPost {
+		// The posted values replace the example, rather than being merged
+		// into it, so that missing values are reported. An empty body
+		// previews the example.
+		var posted map[string]any
+		err := json.NewDecoder(r.Body).Decode(&posted)
+		if err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		} else if err == nil {
+			values = posted
+		}
+	}
+
+	if r.URL.Query().Get("format") == "pdf" {
+		w.Header().Set("Cache-Control", "no-store")
+		s.generate(w, r, t, values)
 		return
 	}
 
+	var page bytes.Buffer
+	err = t.Render(t.WithDefaults(values), assets, extractLocale(r), &page)
+	if errors.Is(err, template.ErrMissingKey) {
+		s.previewError(w, r, http.StatusUnprocessableEntity, fmt.Errorf("failed to render template: %w", err))
+		return
+	} else if err != nil {
+		s.previewError(w, r, http.StatusInternalServerError, fmt.Errorf("failed to render template: %w", err))
+		return
+	}
+
+	result := page.Bytes()
+	if valid := t.Schema.Validate(values); !valid.Valid {
+		result = withValidationErrors(result, validationErrors(valid))
+	}
+	// Reloading a POST request would ask to resubmit it, so only GET previews
+	// are reloaded
+	if s.devTemplates != nil && r.Method == http.MethodGet {
+		result = withReloadScript(result, r.PathValue("template"))
+	}
+
 	w.Header().Set("Content-Type", "text/html")
-	err = t.Render(t.Example, assets, extractLocale(r), w)
-	if err != nil {
-		http.Error(w, "failed to render template: "+err.Error(), http.StatusInternalServerError)
+	_, _ = w.Write(result)
+}
+
+// example is an entry of the list of a template's named examples
+type example struct {
+	Name    string `json:"name"`
+	Preview string `json:"preview"`
+}
+
+// examples lists the named examples of the template, with the URL of their
+// preview
+func (s *server) examples(w http.ResponseWriter, r *http.Request) {
+	// Like the preview, the template is loaded each time to reflect changes
+	t, err := s.loader.Load(r.PathValue("template"))
+	if errors.Is(err, template.ErrTemplateNotFound) {
+		http.Error(w, "template not found", http.StatusNotFound)
 		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	list := make([]example, 0, len(t.Examples))
+	for _, name := range slices.Sorted(maps.Keys(t.Examples)) {
+		list = append(list, example{
+			Name:    name,
+			Preview: fmt.Sprintf("/templates/%s/preview?example=%s", r.PathValue("template"), url.QueryEscape(name)),
+		})
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// validationErrorsTemplate shows validation errors on top of the preview
+var validationErrorsTemplate = htmltemplate.Must(htmltemplate.New("errors").Parse(
+	`<div id="httpdf-validation-errors" style="all: initial; display: block; position: relative; z-index: 2147483647; padding: 12px 16px; background: #fdecea; color: #611a15; border-bottom: 2px solid #f44336; font: 14px/1.4 sans-serif; white-space: pre-wrap">` +
+		`<strong>The values don't match the template's schema:</strong>` +
+		`{{ range . }}` + "\n" + `• {{ . }}{{ end }}</div>`,
+))
+
+// withValidationErrors inserts the validation errors at the start of the body
+// of the page, or at the very start if the page has no body tag
+func withValidationErrors(page []byte, errs []string) []byte {
+	var banner bytes.Buffer
+	_ = validationErrorsTemplate.Execute(&banner, errs)
+
+	pos := 0
+	if m := bodyTag.FindIndex(page); m != nil {
+		pos = m[1]
+	}
+	return slices.Concat(page[:pos], banner.Bytes(), page[pos:])
+}
+
+var bodyTag = regexp.MustCompile(`(?i)<body(\s[^>]*)?>`)
+
+// validationErrors returns the messages of a failed schema validation,
+// prefixed with the location of the invalid value
+func validationErrors(result *jsonschema.EvaluationResult) []string {
+	var errs []string
+	list := result.ToList(false)
+	for _, detail := range append([]jsonschema.List{*list}, list.Details...) {
+		location := detail.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		for _, message := range detail.Errors {
+			errs = append(errs, fmt.Sprintf("%s: %s", location, message))
+		}
+	}
+	slices.Sort(errs)
+	return slices.Compact(errs)
 }
 
 func (s *server) assets(w http.ResponseWriter, r *http.Request) {
@@ -117,9 +530,53 @@ func (s *server) cachedTemplate(name string) (*template.Template, error) {
 	return t, nil
 }
 
+// extractPage reads the page size in mm from the width and height query
+// parameters
+func extractPage(r *http.Request) (pdf.RenderOpts, error) {
+	opts := pdf.RenderOpts{Width: defaultPageWidth, Height: defaultPageHeight}
+	for param, dim := range map[string]*float64{"width": &opts.Width, "height": &opts.Height} {
+		v := r.URL.Query().Get(param)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || n <= 0 {
+			return opts, fmt.Errorf("invalid %s %q", param, v)
+		}
+		*dim = n
+	}
+	return opts, nil
+}
+
 func extractLocale(r *http.Request) string {
 	if locale := r.URL.Query().Get("lang"); locale != "" {
 		return locale
 	}
 	return r.Header.Get("Accept-Language")
 }
+
+// extractEncryption reads the encryption options from the request headers. It
+// returns nil if none of the headers is set.
+func extractEncryption(r *http.Request) *Encryption {
+	userPassword := r.Header.Get(headerUserPassword)
+	ownerPassword := r.Header.Get(headerOwnerPassword)
+	permissions, hasPermissions := r.Header[http.CanonicalHeaderKey(headerPermissions)]
+	if userPassword == "" && ownerPassword == "" && !hasPermissions {
+		return nil
+	}
+
+	e := &Encryption{
+		UserPassword:  userPassword,
+		OwnerPassword: ownerPassword,
+	}
+	if hasPermissions {
+		e.Permissions = []string{}
+		for _, p := range strings.Split(strings.Join(permissions, ","), ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				e.Permissions = append(e.Permissions, p)
+			}
+		}
+	}
+
+	return e
+}
@@ -0,0 +1,400 @@
+package httpdf_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/sehrgutesoftware/httpdf"
+	"github.com/sehrgutesoftware/httpdf/internal/pdf"
+	"github.com/sehrgutesoftware/httpdf/internal/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLoader(t *testing.T) template.Loader {
+	t.Helper()
+
+	root, err := fs.Sub(fstest.MapFS{
+		"hello/template.html":           &fstest.MapFile{Data: []byte(`Hello {{ .name }}!`)},
+		"hello/config.yaml":             &fstest.MapFile{Data: []byte("page:\n  width: 210\n  height: 297\n")},
+		"hello/schema.json":             &fstest.MapFile{Data: []byte(`{"type": "object", "properties": {"name": {"type": "string"}}}`)},
+		"hello/examples/long-name.json": &fstest.MapFile{Data: []byte(`{"name": "Maximiliane Mustermann-Schmidt"}`)},
+		"hello/examples/rtl.json":       &fstest.MapFile{Data: []byte(`{"name": "نور"}`)},
+		"required/template.html":        &fstest.MapFile{Data: []byte(`A={{ .a }} B={{ .b }}`)},
+		"required/config.yaml":          &fstest.MapFile{Data: []byte("page:\n  width: 210\n  height: 297\n")},
+		"required/schema.json":          &fstest.MapFile{Data: []byte(`{"type": "object", "required": ["a", "b"]}`)},
+		"required/example.json":         &fstest.MapFile{Data: []byte(`{"a": "example-a", "b": "example-b"}`)},
+		"strict/template.html":          &fstest.MapFile{Data: []byte(`Hello {{ .name }}!`)},
+		"strict/config.yaml":            &fstest.MapFile{Data: []byte("missingKey: error\n")},
+		"strict/schema.json":            &fstest.MapFile{Data: []byte(`{"type": "object", "properties": {"name": {"type": "string"}}}`)},
+	}, ".")
+	require.NoError(t, err)
+	return template.NewFSLoader(root.(fs.SubFS))
+}
+
+func TestServer_Render(t *testing.T) {
+	diagnostics := pdf.Diagnostics{
+		{Kind: pdf.DiagnosticRequest, Message: "HTTP 404", URL: "http://localhost/assets/logo.png"},
+	}
+
+	t.Run("it_renders_the_template", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/templates/hello/render", strings.NewReader(`{"name": "World"}`)))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "%PDF", rec.Body.String())
+	})
+
+	t.Run("it_exposes_the_diagnostics_if_enabled", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{diagnostics: diagnostics}), testLoader(t), httpdf.WithDiagnosticsHeader())
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/templates/hello/render", strings.NewReader(`{}`)))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var reported pdf.Diagnostics
+		require.NoError(t, json.Unmarshal([]byte(rec.Header().Get("X-PDF-Diagnostics")), &reported))
+		assert.Equal(t, diagnostics, reported)
+	})
+
+	t.Run("it_does_not_expose_the_diagnostics_by_default", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{diagnostics: diagnostics}), testLoader(t))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/templates/hello/render", strings.NewReader(`{}`)))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("X-PDF-Diagnostics"))
+	})
+
+	t.Run("it_returns_422_for_missing_keys_in_error_mode", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/templates/strict/render", strings.NewReader(`{}`)))
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), `1:10: .name: missing key "name"`)
+	})
+}
+
+// multipartForm encodes the files and values as multipart form. Files are
+// given by field name; a field name may contain a file name after a colon.
+func multipartForm(t *testing.T, files map[string]string, values string) (*bytes.Buffer, string) {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for field, content := range files {
+		field, filename, ok := strings.Cut(field, ":")
+		if !ok {
+			filename = field
+		}
+		fw, err := mw.CreateFormFile(field, filename)
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	if values != "" {
+		require.NoError(t, mw.WriteField("values", values))
+	}
+	require.NoError(t, mw.Close())
+	return &body, mw.FormDataContentType()
+}
+
+func TestServer_RenderUpload(t *testing.T) {
+	template := map[string]string{
+		"template.html":   `<img src="{{ asset "logo.png" }}">{{ tr "greeting" }} {{ .name }}!`,
+		"config.yaml":     "page:\n  width: 210\n  height: 297\nlocale:\n  default: en\n",
+		"schema.json":     `{"type": "object", "properties": {"name": {"type": "string"}}}`,
+		"assets:logo.png": "PNG",
+		"locales:en.yaml": "greeting: Hello",
+	}
+
+	t.Run("it_renders_the_uploaded_template", func(t *testing.T) {
+		renderer := &stubRenderer{}
+		server := httpdf.NewServer(httpdf.New(renderer), testLoader(t), httpdf.WithAdHocTemplates())
+		body, contentType := multipartForm(t, template, `{"name": "World"}`)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/render", body)
+		req.Header.Set("Content-Type", contentType)
+		server.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "%PDF", rec.Body.String())
+		assert.Equal(t, `<img src="/assets/logo.png">Hello World!`, renderer.html)
+	})
+
+	t.Run("it_rejects_incomplete_templates", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t), httpdf.WithAdHocTemplates())
+		body, contentType := multipartForm(t, map[string]string{"template.html": "Hello"}, "")
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/render", body)
+		req.Header.Set("Content-Type", contentType)
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "missing config.yaml")
+	})
+
+	t.Run("it_rejects_config_giving_access_to_the_server", func(t *testing.T) {
+		for name, config := range map[string]string{
+			"signature":      "signature:\n  pkcs12: /secrets/signing.p12\n  passwordEnv: SIGNING_PASSWORD\n",
+			"exposedEnvVars": "exposedEnvVars:\n  - SECRET\n",
+			"network.allow":  "network:\n  allow:\n    - \"*\"\n",
+		} {
+			t.Run(name, func(t *testing.T) {
+				renderer := &stubRenderer{}
+				server := httpdf.NewServer(httpdf.New(renderer), testLoader(t), httpdf.WithAdHocTemplates())
+				files := maps.Clone(template)
+				files["config.yaml"] += config
+				body, contentType := multipartForm(t, files, `{"name": "World"}`)
+
+				rec := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodPost, "/render", body)
+				req.Header.Set("Content-Type", contentType)
+				server.ServeHTTP(rec, req)
+
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Contains(t, rec.Body.String(), name)
+				assert.Empty(t, renderer.url)
+			})
+		}
+	})
+
+	t.Run("it_rejects_unexpected_files", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t), httpdf.WithAdHocTemplates())
+		body, contentType := multipartForm(t, map[string]string{"../secret": "x"}, "")
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/render", body)
+		req.Header.Set("Content-Type", contentType)
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("it_is_disabled_by_default", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t))
+		body, contentType := multipartForm(t, template, `{}`)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/render", body)
+		req.Header.Set("Content-Type", contentType)
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestServer_RenderHTML(t *testing.T) {
+	t.Run("it_renders_the_html_body", func(t *testing.T) {
+		renderer := &stubRenderer{}
+		server := httpdf.NewServer(httpdf.New(renderer), testLoader(t))
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/html", strings.NewReader("<h1>Hello</h1>"))
+		req.Header.Set("Content-Type", "text/html; charset=utf-8")
+		server.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "%PDF", rec.Body.String())
+		assert.Equal(t, "<h1>Hello</h1>", renderer.html)
+	})
+
+	t.Run("it_serves_the_assets", func(t *testing.T) {
+		renderer := &stubRenderer{asset: "logo.svg"}
+		server := httpdf.NewServer(httpdf.New(renderer), testLoader(t))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/html", strings.NewReader(
+			`{"html": "<img src=\"logo.svg\">", "assets": {"logo.svg": "PHN2Zy8+"}}`,
+		)))
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "<svg/>", renderer.fetched)
+	})
+
+	t.Run("it_rejects_invalid_page_sizes", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/html?width=-1", strings.NewReader(`{"html": ""}`)))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestServer_RenderURL(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("Intranet"))
+	}))
+	defer page.Close()
+
+	t.Run("it_renders_urls_from_the_allowlist", func(t *testing.T) {
+		renderer := &stubRenderer{}
+		server := httpdf.NewServer(httpdf.New(renderer), testLoader(t), httpdf.WithURLAllowlist("127.0.0.1"))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(`{"url": "`+page.URL+`"}`)))
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "%PDF", rec.Body.String())
+		assert.Equal(t, "Intranet", renderer.html)
+	})
+
+	t.Run("it_rejects_urls_outside_the_allowlist", func(t *testing.T) {
+		renderer := &stubRenderer{}
+		server := httpdf.NewServer(httpdf.New(renderer), testLoader(t), httpdf.WithURLAllowlist("*.example.com"))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(`{"url": "`+page.URL+`"}`)))
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Empty(t, renderer.url)
+	})
+
+	t.Run("it_rejects_non_http_urls", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t), httpdf.WithURLAllowlist("*"))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(`{"url": "file:///etc/passwd"}`)))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestServer_Preview(t *testing.T) {
+	t.Run("it_renders_the_posted_values", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/templates/hello/preview", strings.NewReader(`{"name": "World"}`)))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/html", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "Hello World!", rec.Body.String())
+	})
+
+	t.Run("it_shows_validation_errors_inline", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/templates/hello/preview", strings.NewReader(`{"name": 42}`)))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `id="httpdf-validation-errors"`)
+		assert.Contains(t, rec.Body.String(), "/name: ")
+		assert.True(t, strings.HasSuffix(rec.Body.String(), "Hello 42!"))
+	})
+
+	t.Run("it_doesnt_merge_the_posted_values_into_the_example", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/templates/required/preview", strings.NewReader(`{"b": "posted"}`)))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `id="httpdf-validation-errors"`)
+		assert.NotContains(t, rec.Body.String(), "example-a")
+		assert.True(t, strings.HasSuffix(rec.Body.String(), "B=posted"))
+	})
+
+	t.Run("it_renders_the_example_for_an_empty_body", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/templates/required/preview", strings.NewReader("")))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "A=example-a B=example-b", rec.Body.String())
+	})
+
+	t.Run("it_returns_422_for_missing_keys_in_error_mode", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/templates/strict/preview", strings.NewReader(`{}`)))
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), "missing key")
+	})
+
+	t.Run("it_renders_a_pdf_without_caching", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/templates/hello/preview?format=pdf", strings.NewReader(`{"name": "World"}`)))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		assert.Equal(t, "%PDF", rec.Body.String())
+	})
+
+	t.Run("it_renders_a_named_example", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/templates/hello/preview?example=rtl", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Hello نور!", rec.Body.String())
+	})
+
+	t.Run("it_returns_404_for_unknown_examples", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/templates/hello/preview?example=missing", nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("it_rejects_invalid_json", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/templates/hello/preview", strings.NewReader(`{`)))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestServer_Examples(t *testing.T) {
+	t.Run("it_lists_the_named_examples", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/templates/hello/examples", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.JSONEq(t, `[
+			{"name": "long-name", "preview": "/templates/hello/preview?example=long-name"},
+			{"name": "rtl", "preview": "/templates/hello/preview?example=rtl"}
+		]`, rec.Body.String())
+	})
+
+	t.Run("it_returns_404_for_unknown_templates", func(t *testing.T) {
+		server := httpdf.NewServer(httpdf.New(&stubRenderer{}), testLoader(t))
+
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/templates/missing/examples", nil))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
@@ -0,0 +1,66 @@
+package snapshot
+
+import (
+	"image"
+	"image/color"
+)
+
+// diffColor marks the differing pixels in diff images
+var diffColor = color.RGBA{R: 255, A: 255}
+
+// Compare compares two images pixel by pixel. Pixels whose channels differ by
+// at most tolerance (0-255) are considered equal, which absorbs anti-aliasing
+// noise. It returns the fraction of differing pixels and an image showing the
+// differences in red on a faded copy of want. Images of different sizes are
+// compared on the union of their bounds, where the pixels outside either image
+// count as differing.
+func Compare(got, want image.Image, tolerance uint8) (float64, *image.RGBA) {
+	gb, wb := got.Bounds(), want.Bounds()
+	width := max(gb.Dx(), wb.Dx())
+	height := max(gb.Dy(), wb.Dy())
+	diff := image.NewRGBA(image.Rect(0, 0, width, height))
+	if width == 0 || height == 0 {
+		return 0, diff
+	}
+
+	differing := 0
+	for y := range height {
+		for x := range width {
+			gp := image.Pt(gb.Min.X+x, gb.Min.Y+y)
+			wp := image.Pt(wb.Min.X+x, wb.Min.Y+y)
+			if !gp.In(gb) || !wp.In(wb) {
+				differing++
+				diff.SetRGBA(x, y, diffColor)
+				continue
+			}
+
+			gc := color.RGBAModel.Convert(got.At(gp.X, gp.Y)).(color.RGBA)
+			wc := color.RGBAModel.Convert(want.At(wp.X, wp.Y)).(color.RGBA)
+			if differs(gc, wc, tolerance) {
+				differing++
+				diff.SetRGBA(x, y, diffColor)
+				continue
+			}
+			diff.SetRGBA(x, y, fade(wc))
+		}
+	}
+
+	return float64(differing) / float64(width*height), diff
+}
+
+// differs reports whether any channel differs by more than tolerance
+func differs(a, b color.RGBA, tolerance uint8) bool {
+	for _, d := range [4][2]uint8{{a.R, b.R}, {a.G, b.G}, {a.B, b.B}, {a.A, b.A}} {
+		if max(d[0], d[1])-min(d[0], d[1]) > tolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// fade lightens the color, so the differences stand out in the diff image
+func fade(c color.RGBA) color.RGBA {
+	gray := uint8((uint16(c.R) + uint16(c.G) + uint16(c.B)) / 3)
+	light := 255 - (255-gray)/4
+	return color.RGBA{R: light, G: light, B: light, A: 255}
+}
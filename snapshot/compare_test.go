@@ -0,0 +1,55 @@
+package snapshot_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/sehrgutesoftware/httpdf/snapshot"
+	"github.com/stretchr/testify/assert"
+)
+
+// filled returns an image of the given size and color
+func filled(width, height int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestCompare(t *testing.T) {
+	white := color.RGBA{255, 255, 255, 255}
+
+	t.Run("it_reports_no_difference_for_equal_images", func(t *testing.T) {
+		diff, _ := snapshot.Compare(filled(4, 4, white), filled(4, 4, white), 0)
+
+		assert.Zero(t, diff)
+	})
+
+	t.Run("it_reports_the_fraction_of_differing_pixels", func(t *testing.T) {
+		got := filled(4, 4, white)
+		got.Set(1, 2, color.Black)
+
+		diff, img := snapshot.Compare(got, filled(4, 4, white), 0)
+
+		assert.Equal(t, 1.0/16, diff)
+		assert.Equal(t, color.RGBA{R: 255, A: 255}, img.At(1, 2))
+		assert.NotEqual(t, color.RGBA{R: 255, A: 255}, img.At(0, 0))
+	})
+
+	t.Run("it_ignores_differences_within_the_tolerance", func(t *testing.T) {
+		diff, _ := snapshot.Compare(filled(4, 4, color.RGBA{250, 250, 250, 255}), filled(4, 4, white), 5)
+
+		assert.Zero(t, diff)
+	})
+
+	t.Run("it_counts_pixels_outside_the_smaller_image_as_differing", func(t *testing.T) {
+		diff, img := snapshot.Compare(filled(4, 2, white), filled(4, 4, white), 0)
+
+		assert.Equal(t, 0.5, diff)
+		assert.Equal(t, image.Rect(0, 0, 4, 4), img.Bounds())
+	})
+}
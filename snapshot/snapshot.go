@@ -0,0 +1,245 @@
+// Package snapshot implements visual regression tests for templates. Each
+// example of a template is rendered to PNG and compared against a golden image
+// committed next to the templates.
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/sehrgutesoftware/httpdf"
+	"github.com/sehrgutesoftware/httpdf/internal/template"
+)
+
+var (
+	// ErrMissingGolden is returned for examples without golden image, unless
+	// golden images are updated
+	ErrMissingGolden = errors.New("missing golden image")
+)
+
+// DefaultExample is the name under which the template's example.json is
+// compared
+const DefaultExample = "example"
+
+// Status is the outcome of comparing a single example
+type Status string
+
+const (
+	// StatusPassed means the rendering matches the golden image
+	StatusPassed Status = "passed"
+	// StatusFailed means the rendering differs from the golden image, or
+	// failed altogether
+	StatusFailed Status = "failed"
+	// StatusUpdated means the golden image was written
+	StatusUpdated Status = "updated"
+)
+
+// Options configures a snapshot run
+type Options struct {
+	// Golden is the directory of the golden images, which are stored as
+	// {template}/{example}.png
+	Golden string
+	// Diffs is the directory where diff images of failed comparisons are
+	// written, using the same layout. Defaults to Golden, with a .diff.png
+	// suffix.
+	Diffs string
+	// Threshold is the fraction of pixels that may differ, e.g. 0.001 for
+	// 0.1%
+	Threshold float64
+	// Tolerance is the difference per color channel (0-255) up to which
+	// pixels are considered equal
+	Tolerance uint8
+	// Update writes the current renderings as golden images instead of
+	// comparing them
+	Update bool
+	// Locale is the locale the templates are rendered in
+	Locale string
+}
+
+// Result is the outcome of comparing a single example of a template
+type Result struct {
+	Template string
+	Example  string
+	Status   Status
+	// Diff is the fraction of differing pixels
+	Diff float64
+	// DiffImage is the path of the diff image written on failure
+	DiffImage string
+	// Err is set if the example failed to render or to compare
+	Err error
+}
+
+// Failed reports whether the example doesn't match its golden image
+func (r Result) Failed() bool {
+	return r.Status == StatusFailed
+}
+
+// String describes the result for reports
+func (r Result) String() string {
+	name := r.Template + "/" + r.Example
+	switch {
+	case r.Err != nil:
+		return fmt.Sprintf("FAIL %s: %v", name, r.Err)
+	case r.Status == StatusFailed:
+		return fmt.Sprintf("FAIL %s: %.4f%% of pixels differ, see %s", name, r.Diff*100, r.DiffImage)
+	case r.Status == StatusUpdated:
+		return fmt.Sprintf("UPDATE %s", name)
+	default:
+		return fmt.Sprintf("ok %s", name)
+	}
+}
+
+// Runner renders the examples of templates and compares them against the
+// golden images
+type Runner struct {
+	app    httpdf.HTTPDF
+	loader template.Loader
+	opts   Options
+}
+
+// New creates a new Runner for the templates in the templates directory,
+// e.g. os.DirFS("templates")
+func New(app httpdf.HTTPDF, templates fs.FS, opts Options) *Runner {
+	if opts.Diffs == "" {
+		opts.Diffs = opts.Golden
+	}
+	root, ok := templates.(fs.SubFS)
+	if !ok {
+		root = subFS{templates}
+	}
+	return &Runner{
+		app:    app,
+		loader: template.NewFSLoader(root),
+		opts:   opts,
+	}
+}
+
+// Run compares all examples of the templates: the example.json as
+// DefaultExample, and the named examples. Without templates, all templates
+// are compared. Templates that fail to load are reported as a single failed
+// result.
+func (r *Runner) Run(ctx context.Context, templates ...string) []Result {
+	var results []Result
+	if len(templates) == 0 {
+		var err error
+		if templates, err = r.loader.List(); err != nil {
+			return []Result{{Status: StatusFailed, Err: err}}
+		}
+	}
+	for _, name := range templates {
+		t, err := r.loader.Load(name)
+		if err != nil {
+			results = append(results, Result{Template: name, Status: StatusFailed, Err: err})
+			continue
+		}
+
+		examples := maps.Clone(t.Examples)
+		if examples == nil {
+			examples = make(map[string]map[string]any)
+		}
+		if t.Example != nil {
+			examples[DefaultExample] = t.Example
+		}
+		for _, example := range slices.Sorted(maps.Keys(examples)) {
+			results = append(results, r.compare(ctx, t, name, example, examples[example]))
+		}
+	}
+	return results
+}
+
+// compare renders a single example and compares it against its golden image
+func (r *Runner) compare(ctx context.Context, t *template.Template, name, example string, values map[string]any) Result {
+	result := Result{Template: name, Example: example, Status: StatusFailed}
+	golden := filepath.Join(r.opts.Golden, name, example+".png")
+
+	var rendered bytes.Buffer
+	if err := r.app.Snapshot(ctx, t, r.opts.Locale, values, &rendered); err != nil {
+		result.Err = err
+		return result
+	}
+
+	if r.opts.Update {
+		if err := writeFile(golden, rendered.Bytes()); err != nil {
+			result.Err = err
+			return result
+		}
+		result.Status = StatusUpdated
+		return result
+	}
+
+	got, err := png.Decode(&rendered)
+	if err != nil {
+		result.Err = fmt.Errorf("decode rendering: %w", err)
+		return result
+	}
+	want, err := readImage(golden)
+	if errors.Is(err, os.ErrNotExist) {
+		result.Err = fmt.Errorf("%w: %s", ErrMissingGolden, golden)
+		return result
+	} else if err != nil {
+		result.Err = err
+		return result
+	}
+
+	var diff image.Image
+	result.Diff, diff = Compare(got, want, r.opts.Tolerance)
+	if result.Diff <= r.opts.Threshold {
+		result.Status = StatusPassed
+		return result
+	}
+
+	result.DiffImage = filepath.Join(r.opts.Diffs, name, example+".diff.png")
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, diff); err != nil {
+		result.Err = fmt.Errorf("encode diff image: %w", err)
+		return result
+	}
+	if err := writeFile(result.DiffImage, encoded.Bytes()); err != nil {
+		result.Err = err
+	}
+	return result
+}
+
+// subFS adds fs.SubFS to file systems that don't implement it themselves
+type subFS struct {
+	fs.FS
+}
+
+func (s subFS) Sub(dir string) (fs.FS, error) {
+	return fs.Sub(s.FS, dir)
+}
+
+// readImage decodes the PNG image at path
+func readImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return img, nil
+}
+
+// writeFile writes the file, creating its directory if necessary
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package snapshot_test
+
+import (
+	"context"
+	"image/color"
+	"image/png"
+	"io"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/sehrgutesoftware/httpdf"
+	"github.com/sehrgutesoftware/httpdf/internal/pdf"
+	"github.com/sehrgutesoftware/httpdf/internal/template"
+	"github.com/sehrgutesoftware/httpdf/snapshot"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// colorRenderer renders pages as a 10x10 PNG filled with the color named in
+// the page
+type colorRenderer struct{}
+
+func (r *colorRenderer) Render(ctx context.Context, url string, w io.Writer, opts pdf.RenderOpts) (pdf.Diagnostics, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	page, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	c := color.RGBA{255, 255, 255, 255}
+	if strings.Contains(string(page), "red") {
+		c = color.RGBA{255, 0, 0, 255}
+	}
+	return nil, png.Encode(w, filled(10, 10, c))
+}
+
+func testTemplates(example string) fs.FS {
+	return fstest.MapFS{
+		"invoice/template.html":           &fstest.MapFile{Data: []byte(`{{ .color }}`)},
+		"invoice/config.yaml":             &fstest.MapFile{Data: []byte("page:\n  width: 210\n  height: 297\n")},
+		"invoice/schema.json":             &fstest.MapFile{Data: []byte(`{"type": "object"}`)},
+		"invoice/example.json":            &fstest.MapFile{Data: []byte(`{"color": "white"}`)},
+		"invoice/examples/highlight.json": &fstest.MapFile{Data: []byte(`{"color": "` + example + `"}`)},
+	}
+}
+
+func TestRunner_Run(t *testing.T) {
+	ctx := context.Background()
+	app := httpdf.New(&colorRenderer{})
+
+	t.Run("it_writes_the_golden_images_when_updating", func(t *testing.T) {
+		golden := t.TempDir()
+
+		results := snapshot.New(app, testTemplates("red"), snapshot.Options{Golden: golden, Update: true}).Run(ctx, "invoice")
+
+		require.Len(t, results, 2)
+		assert.Equal(t, "example", results[0].Example)
+		assert.Equal(t, "highlight", results[1].Example)
+		for _, r := range results {
+			assert.Equal(t, snapshot.StatusUpdated, r.Status, r.String())
+		}
+		assert.FileExists(t, filepath.Join(golden, "invoice", "example.png"))
+		assert.FileExists(t, filepath.Join(golden, "invoice", "highlight.png"))
+	})
+
+	t.Run("it_passes_if_the_renderings_match", func(t *testing.T) {
+		golden := t.TempDir()
+		snapshot.New(app, testTemplates("red"), snapshot.Options{Golden: golden, Update: true}).Run(ctx, "invoice")
+
+		results := snapshot.New(app, testTemplates("red"), snapshot.Options{Golden: golden}).Run(ctx, "invoice")
+
+		require.Len(t, results, 2)
+		for _, r := range results {
+			assert.Equal(t, snapshot.StatusPassed, r.Status, r.String())
+		}
+	})
+
+	t.Run("it_fails_and_writes_a_diff_image_if_the_renderings_differ", func(t *testing.T) {
+		golden, diffs := t.TempDir(), t.TempDir()
+		snapshot.New(app, testTemplates("red"), snapshot.Options{Golden: golden, Update: true}).Run(ctx, "invoice")
+
+		results := snapshot.New(app, testTemplates("white"), snapshot.Options{Golden: golden, Diffs: diffs}).Run(ctx, "invoice")
+
+		require.Len(t, results, 2)
+		assert.False(t, results[0].Failed())
+		assert.True(t, results[1].Failed())
+		assert.Equal(t, 1.0, results[1].Diff)
+		assert.Equal(t, filepath.Join(diffs, "invoice", "highlight.diff.png"), results[1].DiffImage)
+		assert.FileExists(t, results[1].DiffImage)
+	})
+
+	t.Run("it_passes_differences_below_the_threshold", func(t *testing.T) {
+		golden := t.TempDir()
+		snapshot.New(app, testTemplates("red"), snapshot.Options{Golden: golden, Update: true}).Run(ctx, "invoice")
+
+		results := snapshot.New(app, testTemplates("white"), snapshot.Options{Golden: golden, Threshold: 1}).Run(ctx, "invoice")
+
+		require.Len(t, results, 2)
+		assert.False(t, results[1].Failed())
+	})
+
+	t.Run("it_fails_for_missing_golden_images", func(t *testing.T) {
+		results := snapshot.New(app, testTemplates("red"), snapshot.Options{Golden: t.TempDir()}).Run(ctx, "invoice")
+
+		require.Len(t, results, 2)
+		assert.ErrorIs(t, results[0].Err, snapshot.ErrMissingGolden)
+	})
+
+	t.Run("it_compares_all_templates_without_names", func(t *testing.T) {
+		// Hides the Sub method of the MapFS
+		templates := struct{ fs.FS }{testTemplates("red")}
+
+		results := snapshot.New(app, templates, snapshot.Options{Golden: t.TempDir(), Update: true}).Run(ctx)
+
+		require.Len(t, results, 2)
+		for _, r := range results {
+			assert.Equal(t, "invoice", r.Template)
+			assert.Equal(t, snapshot.StatusUpdated, r.Status, r.String())
+		}
+	})
+
+	t.Run("it_fails_for_templates_that_fail_to_load", func(t *testing.T) {
+		results := snapshot.New(app, testTemplates("red"), snapshot.Options{Golden: t.TempDir()}).Run(ctx, "missing")
+
+		require.Len(t, results, 1)
+		assert.ErrorIs(t, results[0].Err, template.ErrTemplateNotFound)
+	})
+}
@@ -0,0 +1,128 @@
+package httpdf
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// uploadFS is a read-only in-memory filesystem holding uploaded files, e.g. of
+// an ad-hoc template, by path. Directories are implied by the paths of their
+// files.
+type uploadFS map[string][]byte
+
+func (u uploadFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if data, ok := u[name]; ok {
+		return &uploadFile{
+			Reader: bytes.NewReader(data),
+			info:   uploadInfo{name: path.Base(name), size: int64(len(data))},
+		}, nil
+	}
+
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	dirs := make(map[string]bool)
+	for p := range u {
+		if rest, ok := strings.CutPrefix(p, prefix); ok {
+			child, _, isDir := strings.Cut(rest, "/")
+			dirs[child] = dirs[child] || isDir
+		}
+	}
+	if len(dirs) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	dir := &uploadDir{info: uploadInfo{name: path.Base(name), dir: true}}
+	for _, child := range slices.Sorted(maps.Keys(dirs)) {
+		info := uploadInfo{name: child, dir: dirs[child]}
+		if !info.dir {
+			info.size = int64(len(u[prefix+child]))
+		}
+		dir.entries = append(dir.entries, fs.FileInfoToDirEntry(info))
+	}
+	return dir, nil
+}
+
+// Sub returns the files below dir
+func (u uploadFS) Sub(dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	if dir == "." {
+		return u, nil
+	}
+	sub := make(uploadFS)
+	for p, data := range u {
+		if rest, ok := strings.CutPrefix(p, dir+"/"); ok {
+			sub[rest] = data
+		}
+	}
+	return sub, nil
+}
+
+// uploadInfo describes a file or directory of an uploadFS
+type uploadInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i uploadInfo) Name() string       { return i.name }
+func (i uploadInfo) Size() int64        { return i.size }
+func (i uploadInfo) ModTime() time.Time { return time.Time{} }
+func (i uploadInfo) IsDir() bool        { return i.dir }
+func (i uploadInfo) Sys() any           { return nil }
+
+func (i uploadInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+// uploadFile is an open file of an uploadFS
+type uploadFile struct {
+	*bytes.Reader
+	info uploadInfo
+}
+
+func (f *uploadFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *uploadFile) Close() error               { return nil }
+
+// uploadDir is an open directory of an uploadFS
+type uploadDir struct {
+	info    uploadInfo
+	entries []fs.DirEntry
+}
+
+func (d *uploadDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *uploadDir) Close() error               { return nil }
+
+func (d *uploadDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *uploadDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
@@ -0,0 +1,20 @@
+package httpdf
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadFS(t *testing.T) {
+	t.Run("it_implements_fs_fs", func(t *testing.T) {
+		files := uploadFS{
+			"upload/template.html":   []byte("Hello"),
+			"upload/assets/logo.png": []byte("PNG"),
+			"upload/locales/en.yaml": []byte("greeting: Hello"),
+		}
+
+		assert.NoError(t, fstest.TestFS(files, "upload/template.html", "upload/assets/logo.png", "upload/locales/en.yaml"))
+	})
+}